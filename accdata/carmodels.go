@@ -81,17 +81,18 @@ var (
 		{27, "bmw", "BMW", "M2 CS Racing", 2020, TCX},
 	}
 
-	// carModelsByID is a cache for CarModelByID
+	// carModelsByID is the lookup for CarModelByID; it is filled when the package is initialized
 	carModelsByID map[int]*CarModel
 )
 
+func init() {
+	carModelsByID = make(map[int]*CarModel)
+	for _, model := range CarModels {
+		carModelsByID[model.ID] = model
+	}
+}
+
 // CarModelByID returns the car model with the given ID
 func CarModelByID(ID int) *CarModel {
-	if carModelsByID == nil {
-		carModelsByID = make(map[int]*CarModel)
-		for _, model := range CarModels {
-			carModelsByID[model.ID] = model
-		}
-	}
 	return carModelsByID[ID]
 }
@@ -40,31 +40,30 @@ var (
 		{4, "National", color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255}},
 	}
 
-	// driverCategoriesByID is a cache for DriverCategoryByID
+	// driverCategoriesByID is the lookup for DriverCategoryByID; it is filled when the package is initialized
 	driverCategoriesByID map[int]*DriverCategory
 
-	// cupCategoriesByID is a cache for CupCategoryByID
+	// cupCategoriesByID is the lookup for CupCategoryByID; it is filled when the package is initialized
 	cupCategoriesByID map[int]*CupCategory
 )
 
+func init() {
+	driverCategoriesByID = make(map[int]*DriverCategory)
+	for _, category := range DriverCategories {
+		driverCategoriesByID[category.ID] = category
+	}
+	cupCategoriesByID = make(map[int]*CupCategory)
+	for _, category := range CupCategories {
+		cupCategoriesByID[category.ID] = category
+	}
+}
+
 // DriverCategoryByID returns the driver category with the given ID
 func DriverCategoryByID(ID int) *DriverCategory {
-	if driverCategoriesByID == nil {
-		driverCategoriesByID = make(map[int]*DriverCategory)
-		for _, category := range DriverCategories {
-			driverCategoriesByID[category.ID] = category
-		}
-	}
 	return driverCategoriesByID[ID]
 }
 
 // CupCategoryByID returns the driver category with the given ID
 func CupCategoryByID(ID int) *CupCategory {
-	if cupCategoriesByID == nil {
-		cupCategoriesByID = make(map[int]*CupCategory)
-		for _, category := range CupCategories {
-			cupCategoriesByID[category.ID] = category
-		}
-	}
 	return cupCategoriesByID[ID]
 }
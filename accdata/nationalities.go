@@ -0,0 +1,115 @@
+package accdata
+
+// Nationality describes a single nationality of a driver or car
+type Nationality struct {
+	// ID is the numerical ID of this nationality
+	ID int
+	// Name is the name of the country
+	Name string
+}
+
+var (
+	// Nationalities contains information on all available nationalities
+	Nationalities = []*Nationality{
+		{0, "Any"},
+		{1, "Italy"},
+		{2, "Germany"},
+		{3, "France"},
+		{4, "Spain"},
+		{5, "Great Britain"},
+		{6, "Hungary"},
+		{7, "Belgium"},
+		{8, "Switzerland"},
+		{9, "Austria"},
+		{10, "Russia"},
+		{11, "Thailand"},
+		{12, "Netherlands"},
+		{13, "Poland"},
+		{14, "Argentina"},
+		{15, "Monaco"},
+		{16, "Ireland"},
+		{17, "Brazil"},
+		{18, "South Africa"},
+		{19, "Puerto Rico"},
+		{20, "Slovakia"},
+		{21, "Oman"},
+		{22, "Greece"},
+		{23, "Saudi Arabia"},
+		{24, "Norway"},
+		{25, "Turkey"},
+		{26, "South Korea"},
+		{27, "Lebanon"},
+		{28, "Armenia"},
+		{29, "Mexico"},
+		{30, "Sweden"},
+		{31, "Finland"},
+		{32, "Denmark"},
+		{33, "Croatia"},
+		{34, "Canada"},
+		{35, "China"},
+		{36, "Portugal"},
+		{37, "Singapore"},
+		{38, "Indonesia"},
+		{39, "USA"},
+		{40, "New Zealand"},
+		{41, "Australia"},
+		{42, "San Marino"},
+		{43, "UAE"},
+		{44, "Luxembourg"},
+		{45, "Kuwait"},
+		{46, "Hong Kong"},
+		{47, "Colombia"},
+		{48, "Japan"},
+		{49, "Andorra"},
+		{50, "Azerbaijan"},
+		{51, "Bulgaria"},
+		{52, "Cuba"},
+		{53, "Czech Republic"},
+		{54, "Estonia"},
+		{55, "Georgia"},
+		{56, "India"},
+		{57, "Israel"},
+		{58, "Jamaica"},
+		{59, "Latvia"},
+		{60, "Lithuania"},
+		{61, "Macau"},
+		{62, "Malaysia"},
+		{63, "Nepal"},
+		{64, "New Caledonia"},
+		{65, "Nigeria"},
+		{66, "Northern Ireland"},
+		{67, "Papua New Guinea"},
+		{68, "Philippines"},
+		{69, "Qatar"},
+		{70, "Romania"},
+		{71, "Scotland"},
+		{72, "Serbia"},
+		{73, "Slovenia"},
+		{74, "Taiwan"},
+		{75, "Ukraine"},
+		{76, "Venezuela"},
+		{77, "Wales"},
+		{78, "Iran"},
+		{79, "Bahrain"},
+		{80, "Zimbabwe"},
+		{81, "Chinese Taipei"},
+		{82, "Chile"},
+		{83, "Uruguay"},
+		{84, "Madagascar"},
+	}
+
+	// nationalitiesByID is the lookup for NationalityByID; it is filled when the package is initialized
+	nationalitiesByID map[int]*Nationality
+)
+
+func init() {
+	nationalitiesByID = make(map[int]*Nationality)
+	for _, nationality := range Nationalities {
+		nationalitiesByID[nationality.ID] = nationality
+	}
+}
+
+// NationalityByID returns the nationality with the given ID
+func NationalityByID(ID int) *Nationality {
+	return nationalitiesByID[ID]
+}
@@ -85,20 +85,21 @@ var (
 			[]string{}},
 	}
 
-	// tracksByLabel is a cache for TrackByLabel
+	// tracksByLabel is the lookup for TrackByLabel; it is filled when the package is initialized
 	tracksByLabel map[string]*Track
 )
 
-// TrackByLabel returns the track for a given label
-func TrackByLabel(label string) *Track {
-	if tracksByLabel == nil {
-		tracksByLabel = make(map[string]*Track)
-		for _, track := range Tracks {
-			tracksByLabel[track.Label] = track
-			for _, label := range track.AlternateLabels {
-				tracksByLabel[label] = track
-			}
+func init() {
+	tracksByLabel = make(map[string]*Track)
+	for _, track := range Tracks {
+		tracksByLabel[track.Label] = track
+		for _, label := range track.AlternateLabels {
+			tracksByLabel[label] = track
 		}
 	}
+}
+
+// TrackByLabel returns the track for a given label
+func TrackByLabel(label string) *Track {
 	return tracksByLabel[label]
 }
@@ -0,0 +1,113 @@
+package accresults
+
+import (
+	"encoding/gob"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	// parseCacheVersion must be incremented whenever the structure of a Session changes, to invalidate old caches
+	parseCacheVersion = 3
+)
+
+// parseCacheEntry contains a parsed session, together with the properties of the file it was parsed from
+type parseCacheEntry struct {
+	Size    int64
+	ModTime time.Time
+	Session *Session
+}
+
+// parseCacheContents is the contents of the cache file
+type parseCacheContents struct {
+	Version int
+	Options Options
+	Entries map[string]*parseCacheEntry
+}
+
+// parseCache contains parsed sessions, to avoid parsing unchanged results files again
+//
+// Sessions are cached on their name, which is unique across sources. A cached session is only used if the size and
+// modification time of the file are unchanged. The whole cache is invalid when the options have changed.
+type parseCache struct {
+	path string
+	// old contains the entries loaded from disk
+	old map[string]*parseCacheEntry
+	// current contains the entries for all files that were seen since loading
+	current *parseCacheContents
+}
+
+// loadParseCache loads the cache from the given path. An empty path disables the cache.
+func loadParseCache(path string, options Options) *parseCache {
+	cache := &parseCache{
+		path: path,
+		old:  make(map[string]*parseCacheEntry),
+		current: &parseCacheContents{
+			Version: parseCacheVersion,
+			Options: options,
+			Entries: make(map[string]*parseCacheEntry),
+		},
+	}
+	if path == "" {
+		return cache
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return cache
+	} else if err != nil {
+		log.Printf("Cannot open results cache '%s': %v", path, err)
+		return cache
+	}
+	defer file.Close()
+
+	var contents parseCacheContents
+	if err := gob.NewDecoder(file).Decode(&contents); err != nil {
+		log.Printf("Ignoring invalid results cache '%s': %v", path, err)
+	} else if contents.Version != parseCacheVersion || contents.Options != options {
+		log.Printf("Ignoring results cache '%s' because it was created with different options", path)
+	} else {
+		cache.old = contents.Entries
+	}
+	return cache
+}
+
+// lookup returns the cached session with the given name, or nil if it is not cached or its file has changed since
+func (c *parseCache) lookup(sessionName string, info os.FileInfo) *Session {
+	entry, ok := c.old[sessionName]
+	if !ok || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		return nil
+	}
+	c.current.Entries[sessionName] = entry
+	return entry.Session
+}
+
+// store adds a freshly parsed session to the cache
+func (c *parseCache) store(sessionName string, info os.FileInfo, session *Session) {
+	c.current.Entries[sessionName] = &parseCacheEntry{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Session: session,
+	}
+}
+
+// save writes all entries for files seen since loading to disk, which drops the entries for removed files
+func (c *parseCache) save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	file, err := os.Create(c.path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(file).Encode(c.current); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(c.path+".tmp", c.path)
+}
@@ -3,13 +3,12 @@ package accresults
 import (
 	"io/ioutil"
 	"log"
-	"path/filepath"
+	"math"
+	"os"
 	"sort"
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/fsnotify/fsnotify"
 )
 
 var (
@@ -30,17 +29,21 @@ type Options struct {
 
 // Configuration contains the configuration options for the database
 type Configuration struct {
-	// ResultsDir is the directory which contains the results files
-	ResultsDir string
+	// Sources contains the directories which contain the results files
+	Sources []*Source
 	// NewFileDelay is the number of seconds to wait after a new file appears before reading it
 	NewFileDelay int
+	// PollInterval is the number of seconds between scans of the results dir; file system events are used to detect
+	// changes if it is 0
+	PollInterval int
 	// Options contains the options which influence result parsing and interpretation
 	Options Options
-}
-
-// resultsDir returns the ResultsDir with a single slash at the end
-func (c *Configuration) resultsDir() string {
-	return strings.TrimRight(c.ResultsDir, "/") + "/"
+	// CachePath is the file in which parsed sessions are cached between runs; caching is disabled if it is empty
+	CachePath string
+	// EventGrouping contains the rules to group sessions into events
+	EventGrouping EventGrouping
+	// EventOverridesPath is the file in which manual splits and merges of events are stored
+	EventOverridesPath string
 }
 
 // Event identifies an event consisting of one or more sessions
@@ -49,6 +52,8 @@ type Event struct {
 	TrackName string
 	EndTime   time.Time
 	Sessions  []*Session
+	// Source is the name of the source of all sessions in the event
+	Source string
 }
 
 // Database contains the results and derived data as obtained from parsing the result files
@@ -67,8 +72,21 @@ type Database struct {
 
 	// Events contains all events keyed on event ID
 	Events map[string]*Event
-	// lastEvent is the last event that was added to the database
-	lastEvent *Event
+	// sources contains the directories which contain the results files
+	sources []*Source
+	// lastEvents contains the last event that was added to the database per source
+	lastEvents map[string]*Event
+	// grouping contains the rules to group sessions into events
+	grouping EventGrouping
+	// overrides contains the manual splits and merges of events
+	overrides *eventOverrides
+	// dumps contains the entry lists and intermediate leaderboards dumped by accServer
+	dumps *dumps
+
+	// progress keeps track of loading the results files from disk
+	progress *loadProgress
+	// failed contains the session results files which could not be loaded
+	failed *quarantine
 }
 
 func (db *Database) getOrCreatePlayer(playerId string) *Player {
@@ -92,14 +110,33 @@ func (db *Database) resolvePlayersInSession(sessionName string, session *Session
 }
 
 func (db *Database) resolveEventForSession(session *Session) *Event {
-	if db.lastEvent.TrackName != session.TrackName || session.SessionIndex == 0 {
+	lastEvent := db.lastEvents[session.Source]
+	if db.startsNewEvent(lastEvent, session) {
 		eventId := strings.TrimRight(session.SessionName, "_FPQR")
-		db.lastEvent = &Event{eventId, session.TrackName, session.EndTime, nil}
-		db.Events[eventId] = db.lastEvent
+		lastEvent = &Event{eventId, session.TrackName, session.EndTime, nil, session.Source}
+		db.Events[eventId] = lastEvent
+		db.lastEvents[session.Source] = lastEvent
+	}
+	lastEvent.EndTime = session.EndTime
+	lastEvent.Sessions = append(lastEvent.Sessions, session)
+	return lastEvent
+}
+
+// startsNewEvent checks if a session starts a new event, based on the manual overrides and the grouping rules
+func (db *Database) startsNewEvent(lastEvent *Event, session *Session) bool {
+	var previous *Session
+	if lastEvent != nil {
+		previous = lastEvent.Sessions[len(lastEvent.Sessions)-1]
+	}
+
+	switch db.overrides.overrides[session.SessionName] {
+	case EventOverrideSplit:
+		return true
+	case EventOverrideMerge:
+		// Sessions on different tracks never form a single event
+		return previous == nil || previous.TrackName != session.TrackName
 	}
-	db.lastEvent.EndTime = session.EndTime
-	db.lastEvent.Sessions = append(db.lastEvent.Sessions, session)
-	return db.lastEvent
+	return db.grouping.startsNewEvent(previous, session)
 }
 
 func (db *Database) addSession(sessionName string, session *Session) {
@@ -110,7 +147,63 @@ func (db *Database) addSession(sessionName string, session *Session) {
 	db.resolvePlayersInSession(sessionName, session, event)
 }
 
-func isSessionFile(fileName string) bool {
+// rebuild recreates all players and events from the sessions in the database, which is needed after a session was
+// changed or removed. The dump files are attached to the sessions again, and intermediate sessions are recreated
+// from them. The caller must hold the write lock.
+func (db *Database) rebuild() {
+	sessions := make(map[string]*Session, len(db.Sessions))
+	for sessionName, session := range db.Sessions {
+		if !session.Intermediate {
+			sessions[sessionName] = session
+		}
+	}
+	for sessionName, session := range db.attachDumps(sessions) {
+		if _, exists := sessions[sessionName]; !exists {
+			sessions[sessionName] = session
+		}
+	}
+
+	sessionNames := make([]string, 0, len(sessions))
+	for sessionName := range sessions {
+		sessionNames = append(sessionNames, sessionName)
+	}
+	sort.Strings(sessionNames)
+
+	db.Sessions = make(map[string]*Session)
+	db.Players = make(map[string]*Player)
+	db.Events = make(map[string]*Event)
+	db.lastEvents = make(map[string]*Event)
+	for _, sessionName := range sessionNames {
+		db.addSession(sessionName, sessions[sessionName])
+	}
+}
+
+// BestLap returns the fastest lap on a track, optionally limited to a single car model and/or player. A carModel
+// of -1 and an empty playerId include all car models and players. It returns 0 if no lap is known.
+func (db *Database) BestLap(trackName string, carModel int, playerId string) int {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	best := math.MaxInt32
+	if playerId != "" {
+		if player := db.Players[playerId]; player != nil {
+			best = player.bestLap(trackName, carModel)
+		}
+	} else {
+		for _, player := range db.Players {
+			if lap := player.bestLap(trackName, carModel); lap < best {
+				best = lap
+			}
+		}
+	}
+
+	if best >= math.MaxInt32 {
+		return 0
+	}
+	return best
+}
+
+func IsSessionFile(fileName string) bool {
 	return strings.HasSuffix(fileName, "_FP.json") ||
 		strings.HasSuffix(fileName, "_Q.json") ||
 		strings.HasSuffix(fileName, "_R.json")
@@ -125,15 +218,63 @@ func parseTimeFromSessionName(name string) time.Time {
 	return result
 }
 
-func (db *Database) loadSessionFile(resultsPath string, fileName string) {
-	sessionName := strings.TrimSuffix(fileName, ".json")
-	sessionTime := parseTimeFromSessionName(sessionName)
-	session, err := LoadSessionFromFile(resultsPath+fileName, sessionTime)
+// parseSessionFile parses a session results file of a source
+func parseSessionFile(source *Source, fileName string) (*Session, error) {
+	sessionTime := parseTimeFromSessionName(strings.TrimSuffix(fileName, ".json"))
+	session, err := LoadSessionFromFile(source.dir()+fileName, sessionTime)
+	if session != nil {
+		session.Source = source.Name
+	}
+	return session, err
+}
+
+// loadSessionFile loads a new or modified session results file into the database. If the file cannot be parsed, the
+// database is left unchanged and the file is quarantined.
+func (db *Database) loadSessionFile(source *Source, fileName string) {
+	session, err := parseSessionFile(source, fileName)
 	if err != nil {
 		log.Printf("Error loading session results file '%v': %v", fileName, err)
+		db.failed.add(source, fileName, err)
 		return
 	}
+	db.failed.remove(source, fileName)
 
+	sessionName := source.sessionName(fileName)
+	db.applyFiltersToSession(session)
+
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	_, exists := db.Sessions[sessionName]
+	if db.isSessionFiltered(session) {
+		if exists {
+			delete(db.Sessions, sessionName)
+			db.rebuild()
+		}
+	} else if exists || db.hasDumpsFor(session) {
+		db.Sessions[sessionName] = session
+		db.rebuild()
+	} else {
+		db.addSession(sessionName, session)
+	}
+}
+
+// removeSessionFile removes the session loaded from a session results file which no longer exists
+func (db *Database) removeSessionFile(source *Source, fileName string) {
+	sessionName := source.sessionName(fileName)
+	db.failed.remove(source, fileName)
+
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	if _, exists := db.Sessions[sessionName]; exists {
+		delete(db.Sessions, sessionName)
+		db.rebuild()
+	}
+}
+
+// addParsedSession adds a session with the given name to the database, unless it is filtered out
+func (db *Database) addParsedSession(sessionName string, session *Session) {
 	db.applyFiltersToSession(session)
 	if !db.isSessionFiltered(session) {
 		db.Mutex.Lock()
@@ -152,82 +293,104 @@ func (db *Database) isSessionFiltered(session *Session) bool {
 	return len(session.SessionResult.LeaderBoardLines) == 0
 }
 
-func (db *Database) monitorResultsDir(config *Configuration) {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		log.Fatal(err)
+// newDatabase creates an empty database and lists the session and dump files in all sources, sorted by name per source
+func newDatabase(config *Configuration) (*Database, []*sourceFile, error) {
+	if err := validateSources(config.Sources); err != nil {
+		return nil, nil, err
 	}
-	err = watcher.Add(config.resultsDir())
+	if err := config.EventGrouping.validate(); err != nil {
+		return nil, nil, err
+	}
+	overrides, err := loadEventOverrides(config.EventOverridesPath)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
 	}
 
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("Aborting watcher due to error: %v", r)
-		}
-		log.Print("Closing results dir watcher...")
-		if err := watcher.Close(); err != nil {
-			log.Printf("Error closing results dir watcher: %v", err)
+	var db = &Database{
+		config.Options,
+		&sync.RWMutex{},
+		make(map[string]*Session),
+		make(map[string]*Player),
+		make(map[string]*Event),
+		config.Sources,
+		make(map[string]*Event),
+		config.EventGrouping,
+		overrides,
+		newDumps(),
+		&loadProgress{},
+		newQuarantine(),
+	}
+
+	sessionFiles := make([]*sourceFile, 0)
+	for _, source := range config.Sources {
+		files, err := ioutil.ReadDir(source.dir())
+		if err != nil {
+			return nil, nil, err
 		}
-	}()
 
-	for {
-		select {
-		case event, ok := <-watcher.Events:
-			if !ok {
-				return
-			}
-			fileName := filepath.Base(event.Name)
-			if event.Op&fsnotify.Create == fsnotify.Create {
-				time.AfterFunc(time.Duration(config.NewFileDelay)*time.Second, func() {
-					if isSessionFile(fileName) {
-						log.Printf("Loading new session file '%s'", fileName)
-						db.loadSessionFile(config.resultsDir(), fileName)
-					} else {
-						log.Printf("Ignoring file '%s' because it is not a session results file", fileName)
-					}
-				})
+		sort.Slice(files, func(i, j int) bool {
+			return files[i].Name() < files[j].Name()
+		})
+
+		for _, f := range files {
+			if isResultsFile(f.Name()) {
+				sessionFiles = append(sessionFiles, &sourceFile{source, f})
+			} else {
+				log.Printf("Ignoring file '%s' because it is not a results file", f.Name())
 			}
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				return
+		}
+	}
+
+	return db, sessionFiles, nil
+}
+
+// SourceNames returns the names of all sources, in the order of the configuration
+func (db *Database) SourceNames() []string {
+	names := make([]string, 0, len(db.sources))
+	for _, source := range db.sources {
+		names = append(names, source.Name)
+	}
+	return names
+}
+
+// startMonitoring starts monitoring all sources for changes in the background
+func (db *Database) startMonitoring(config *Configuration, files []*sourceFile) {
+	for _, source := range config.Sources {
+		sourceFiles := make([]os.FileInfo, 0)
+		for _, file := range files {
+			if file.source == source {
+				sourceFiles = append(sourceFiles, file.info)
 			}
-			log.Panic(err)
 		}
+		go newResultsDirMonitor(db, config, source, sourceFiles).run()
 	}
 }
 
 // LoadDatabase loads a database from disk and starts monitoring it
 func LoadDatabase(config *Configuration) (*Database, error) {
-	var db = &Database{
-		config.Options,
-		&sync.RWMutex{},
-		make(map[string]*Session),
-		make(map[string]*Player),
-		make(map[string]*Event),
-		&Event{"__", "__", time.Now(), nil},
-	}
-
-	files, err := ioutil.ReadDir(config.resultsDir())
+	db, files, err := newDatabase(config)
 	if err != nil {
 		return nil, err
 	}
 
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].Name() < files[j].Name()
-	})
+	db.load(config, files)
+	db.startMonitoring(config, files)
 
-	for _, f := range files {
-		fileName := f.Name()
-		if isSessionFile(fileName) {
-			db.loadSessionFile(config.resultsDir(), fileName)
-		} else {
-			log.Printf("Ignoring file '%s' because it is not a session results file", fileName)
-		}
+	return db, nil
+}
+
+// LoadDatabaseInBackground returns an empty database, which is loaded from disk and monitored in the background.
+// LoadProgress reports how far loading has progressed.
+func LoadDatabaseInBackground(config *Configuration) (*Database, error) {
+	db, files, err := newDatabase(config)
+	if err != nil {
+		return nil, err
 	}
 
-	go db.monitorResultsDir(config)
+	go func() {
+		db.load(config, files)
+		db.startMonitoring(config, files)
+	}()
 
-	return db, err
+	return db, nil
 }
@@ -0,0 +1,387 @@
+package accresults
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// entryListSuffix is the suffix of entry lists dumped by accServer with dumpEntryList
+	entryListSuffix = "_entrylist.json"
+	// leaderboardSuffix is the suffix of intermediate leaderboards dumped by accServer with dumpLeaderboards
+	leaderboardSuffix = "_leaderboard.json"
+	// entryListMaxGap is the maximum time between the end of a session and the entry list dumped at its end
+	entryListMaxGap = 5 * time.Minute
+)
+
+// EntryListDriver is a single driver of an entry in a dumped entry list
+type EntryListDriver struct {
+	FirstName      string `json:"firstName"`
+	LastName       string `json:"lastName"`
+	ShortName      string `json:"shortName"`
+	Nationality    int    `json:"nationality"`
+	DriverCategory int    `json:"driverCategory"`
+	PlayerID       string `json:"playerID"`
+}
+
+// EntryListEntry is a single car in a dumped entry list. ForcedCarModel is nil if it is missing from the file; use
+// ForcedCarModelID to read it.
+type EntryListEntry struct {
+	Drivers             []*EntryListDriver `json:"drivers"`
+	RaceNumber          int                `json:"raceNumber"`
+	ForcedCarModel      *int               `json:"forcedCarModel"`
+	OverrideDriverInfo  int                `json:"overrideDriverInfo"`
+	IsServerAdmin       int                `json:"isServerAdmin"`
+	BallastKg           int                `json:"ballastKg"`
+	Restrictor          int                `json:"restrictor"`
+	DefaultGridPosition int                `json:"defaultGridPosition"`
+}
+
+// ForcedCarModelID returns the car model forced by the entry list, or -1 if the car model is not forced
+func (entry *EntryListEntry) ForcedCarModelID() int {
+	if entry.ForcedCarModel == nil {
+		return -1
+	}
+	return *entry.ForcedCarModel
+}
+
+// EntryList is an entry list dumped by accServer at the end of a qualifying session
+type EntryList struct {
+	Entries        []*EntryListEntry `json:"entries"`
+	ForceEntryList int               `json:"forceEntryList"`
+
+	// Time is the time the entry list was dumped
+	Time time.Time
+	// Source is the name of the source of the entry list file
+	Source string
+}
+
+// dumps contains the entry lists and intermediate leaderboards dumped by accServer, keyed on the name of their file
+// in the same way as sessions
+type dumps struct {
+	entryLists map[string]*EntryList
+	// leaderboards contains the intermediate leaderboards, which are in the same format as session results files
+	leaderboards map[string]*Session
+}
+
+func newDumps() *dumps {
+	return &dumps{
+		entryLists:   make(map[string]*EntryList),
+		leaderboards: make(map[string]*Session),
+	}
+}
+
+func (d *dumps) isEmpty() bool {
+	return len(d.entryLists) == 0 && len(d.leaderboards) == 0
+}
+
+func isDumpFile(fileName string) bool {
+	return strings.HasSuffix(fileName, entryListSuffix) ||
+		strings.HasSuffix(fileName, leaderboardSuffix)
+}
+
+// isResultsFile checks if a file in a results dir is loaded into the database
+func isResultsFile(fileName string) bool {
+	return IsSessionFile(fileName) || isDumpFile(fileName)
+}
+
+// parseTimeFromDumpName parses the time from the name of a dump file, which starts with the time like session files
+func parseTimeFromDumpName(fileName string) (time.Time, error) {
+	const layout = "060102_150405"
+	if len(fileName) < len(layout) {
+		return time.Time{}, fmt.Errorf("no time in file name '%s'", fileName)
+	}
+	return time.ParseInLocation(layout, fileName[:len(layout)], time.Local)
+}
+
+func parseEntryListFile(source *Source, fileName string) (*EntryList, error) {
+	dumpTime, err := parseTimeFromDumpName(fileName)
+	if err != nil {
+		return nil, err
+	}
+	fileContents, err := readUtf16File(source.dir() + fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var entryList EntryList
+	if err := json.Unmarshal(fileContents, &entryList); err != nil {
+		return nil, err
+	}
+	entryList.Time = dumpTime
+	entryList.Source = source.Name
+	return &entryList, nil
+}
+
+func parseLeaderboardFile(source *Source, fileName string) (*Session, error) {
+	dumpTime, err := parseTimeFromDumpName(fileName)
+	if err != nil {
+		return nil, err
+	}
+	leaderboard, err := LoadSessionFromFile(source.dir()+fileName, dumpTime)
+	if leaderboard != nil {
+		leaderboard.Source = source.Name
+	}
+	return leaderboard, err
+}
+
+// FindEntryByRaceNumber returns the entry of a car, or nil if the race number is not in the entry list
+func (l *EntryList) FindEntryByRaceNumber(raceNumber int) *EntryListEntry {
+	for _, entry := range l.Entries {
+		if entry.RaceNumber == raceNumber {
+			return entry
+		}
+	}
+	return nil
+}
+
+// addDumpFile parses a dump file and adds it to the database, without attaching it to any session
+func (db *Database) addDumpFile(source *Source, fileName string) error {
+	name := source.sessionName(fileName)
+	if strings.HasSuffix(fileName, entryListSuffix) {
+		entryList, err := parseEntryListFile(source, fileName)
+		if err != nil {
+			return err
+		}
+		db.Mutex.Lock()
+		defer db.Mutex.Unlock()
+		db.dumps.entryLists[name] = entryList
+		return nil
+	}
+
+	leaderboard, err := parseLeaderboardFile(source, fileName)
+	if err != nil {
+		return err
+	}
+	db.applyFiltersToSession(leaderboard)
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+	db.dumps.leaderboards[name] = leaderboard
+	return nil
+}
+
+// loadDumpFile loads a new or modified dump file into the database. If the file cannot be parsed, the database is
+// left unchanged and the file is quarantined.
+func (db *Database) loadDumpFile(source *Source, fileName string) {
+	if err := db.addDumpFile(source, fileName); err != nil {
+		log.Printf("Error loading dump file '%v': %v", fileName, err)
+		db.failed.add(source, fileName, err)
+		return
+	}
+	db.failed.remove(source, fileName)
+
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+	db.rebuild()
+}
+
+// removeDumpFile removes a dump file which no longer exists from the database
+func (db *Database) removeDumpFile(source *Source, fileName string) {
+	name := source.sessionName(fileName)
+	db.failed.remove(source, fileName)
+
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	delete(db.dumps.entryLists, name)
+	delete(db.dumps.leaderboards, name)
+	db.rebuild()
+}
+
+// loadResultsFile loads a new or modified session results file or dump file into the database
+func (db *Database) loadResultsFile(source *Source, fileName string) {
+	if isDumpFile(fileName) {
+		db.loadDumpFile(source, fileName)
+	} else {
+		db.loadSessionFile(source, fileName)
+	}
+}
+
+// removeResultsFile removes a session results file or dump file which no longer exists from the database
+func (db *Database) removeResultsFile(source *Source, fileName string) {
+	if isDumpFile(fileName) {
+		db.removeDumpFile(source, fileName)
+	} else {
+		db.removeSessionFile(source, fileName)
+	}
+}
+
+// isSameSession checks if an intermediate leaderboard could have been dumped during a session
+func (session *Session) isSameSession(leaderboard *Session) bool {
+	return session.Source == leaderboard.Source &&
+		session.TrackName == leaderboard.TrackName &&
+		session.SessionType == leaderboard.SessionType &&
+		session.SessionIndex == leaderboard.SessionIndex &&
+		session.RaceWeekendIndex == leaderboard.RaceWeekendIndex
+}
+
+// attachDumps attaches the dumped entry lists and intermediate leaderboards to the given sessions, and returns the
+// intermediate sessions for leaderboards dumped during sessions which ended without a results file. The caller must
+// hold the write lock.
+//
+// A leaderboard belongs to the first session of its source which ended after it was dumped, because a server runs
+// one session at a time. If that session does not match the leaderboard, the session during which it was dumped
+// ended abnormally, and it is shown from its last leaderboard instead. An entry list belongs to the session of its
+// source which ended closest to the time it was dumped.
+func (db *Database) attachDumps(sessions map[string]*Session) map[string]*Session {
+	bySource := make(map[string][]*Session)
+	for _, session := range sessions {
+		session.entryList = nil
+		session.leaderboards = nil
+		bySource[session.Source] = append(bySource[session.Source], session)
+	}
+	for _, list := range bySource {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].EndTime.Before(list[j].EndTime)
+		})
+	}
+
+	names := make([]string, 0, len(db.dumps.leaderboards))
+	for name := range db.dumps.leaderboards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	intermediate := make(map[string]*Session)
+	var current *Session
+	var currentNext int
+	for _, name := range names {
+		leaderboard := db.dumps.leaderboards[name]
+		list := bySource[leaderboard.Source]
+		next := sort.Search(len(list), func(i int) bool {
+			return !list[i].EndTime.Before(leaderboard.EndTime)
+		})
+		if next < len(list) && list[next].isSameSession(leaderboard) {
+			list[next].leaderboards = append(list[next].leaderboards, leaderboard)
+			continue
+		}
+
+		// Consecutive leaderboards of a session without results file form a single intermediate session, which is
+		// named after its last leaderboard
+		if current == nil || currentNext != next || !current.isSameSession(leaderboard) {
+			current = &Session{}
+			currentNext = next
+		}
+		leaderboards := append(current.leaderboards, leaderboard)
+		delete(intermediate, current.SessionName)
+		*current = *leaderboard
+		baseName := strings.TrimSuffix(name, strings.TrimSuffix(leaderboardSuffix, ".json"))
+		current.SessionName = baseName + "_" + string(leaderboard.SessionType)
+		current.Intermediate = true
+		current.leaderboards = leaderboards
+		intermediate[current.SessionName] = current
+	}
+	for _, session := range intermediate {
+		bySource[session.Source] = append(bySource[session.Source], session)
+	}
+
+	for _, entryList := range db.dumps.entryLists {
+		var closest *Session
+		for _, session := range bySource[entryList.Source] {
+			if gap := absDuration(session.EndTime.Sub(entryList.Time)); gap <= entryListMaxGap &&
+				(closest == nil || gap < absDuration(closest.EndTime.Sub(entryList.Time))) {
+				closest = session
+			}
+		}
+		if closest != nil && (closest.entryList == nil || closest.entryList.Time.Before(entryList.Time)) {
+			closest.entryList = entryList
+		}
+	}
+
+	return intermediate
+}
+
+// hasDumpsFor checks if any dump would be attached to a new session, or to an intermediate session which the new
+// session replaces. Only then the dumps need to be attached to all sessions again. The caller must hold the lock.
+func (db *Database) hasDumpsFor(session *Session) bool {
+	for _, entryList := range db.dumps.entryLists {
+		if entryList.Source == session.Source && absDuration(session.EndTime.Sub(entryList.Time)) <= entryListMaxGap {
+			return true
+		}
+	}
+
+	// Leaderboards dumped after the previous session of the source ended move to the new session
+	var previousEnd time.Time
+	for _, other := range db.Sessions {
+		if !other.Intermediate && other.Source == session.Source && other.EndTime.Before(session.EndTime) &&
+			other.EndTime.After(previousEnd) {
+			previousEnd = other.EndTime
+		}
+	}
+	for _, leaderboard := range db.dumps.leaderboards {
+		if leaderboard.Source == session.Source && !leaderboard.EndTime.After(session.EndTime) &&
+			leaderboard.EndTime.After(previousEnd) {
+			return true
+		}
+	}
+	return false
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// EntryList returns the entry list dumped at the end of the session, or nil if there is none
+func (session *Session) EntryList() *EntryList {
+	return session.entryList
+}
+
+// Leaderboards returns the intermediate leaderboards dumped during the session, in the order they were dumped
+func (session *Session) Leaderboards() []*Session {
+	return session.leaderboards
+}
+
+// CarProgress contains the position of a car in every intermediate leaderboard of a session
+type CarProgress struct {
+	Line *LeaderBoardLine
+	// Positions contains the 1-based position per leaderboard, or 0 if the car was not in that leaderboard
+	Positions []int
+}
+
+// Progress returns the position of every car in the intermediate leaderboards, in the order of the final leaderboard
+func (session *Session) Progress() []*CarProgress {
+	progress := make([]*CarProgress, 0, len(session.SessionResult.LeaderBoardLines))
+	for _, line := range session.SessionResult.LeaderBoardLines {
+		positions := make([]int, len(session.leaderboards))
+		for i, leaderboard := range session.leaderboards {
+			for pos, other := range leaderboard.SessionResult.LeaderBoardLines {
+				if other.Car.CarId == line.Car.CarId {
+					positions[i] = pos + 1
+					break
+				}
+			}
+		}
+		progress = append(progress, &CarProgress{line, positions})
+	}
+	return progress
+}
+
+// EntryList returns the latest entry list dumped during the event, or nil if there is none
+func (e *Event) EntryList() *EntryList {
+	for i := len(e.Sessions) - 1; i >= 0; i-- {
+		if entryList := e.Sessions[i].EntryList(); entryList != nil {
+			return entryList
+		}
+	}
+	return nil
+}
+
+// FindCarByRaceNumber returns the car with a race number in the latest session of the event it took part in, or nil
+// if no car had that number
+func (e *Event) FindCarByRaceNumber(raceNumber int) *Car {
+	for i := len(e.Sessions) - 1; i >= 0; i-- {
+		for _, line := range e.Sessions[i].SessionResult.LeaderBoardLines {
+			if line.Car.RaceNumber == raceNumber {
+				return line.Car
+			}
+		}
+	}
+	return nil
+}
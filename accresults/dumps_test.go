@@ -0,0 +1,156 @@
+package accresults
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testDumpTime returns a time on the day of the dump tests
+func testDumpTime(hour, minute int) time.Time {
+	return time.Date(2020, 1, 1, hour, minute, 0, 0, time.UTC)
+}
+
+// newTestDumpSession creates a session, or an intermediate leaderboard, of the first race weekend on Monza
+func newTestDumpSession(source string, sessionType SessionType, sessionIndex int, endTime time.Time) *Session {
+	return &Session{
+		Source:       source,
+		TrackName:    "monza",
+		SessionType:  sessionType,
+		SessionIndex: sessionIndex,
+		EndTime:      endTime,
+	}
+}
+
+// newTestDumpDatabase creates a database with only the given dumps
+func newTestDumpDatabase(leaderboards map[string]*Session, entryLists ...*EntryList) *Database {
+	db := &Database{dumps: newDumps()}
+	db.dumps.leaderboards = leaderboards
+	for i, entryList := range entryLists {
+		db.dumps.entryLists[string(rune('a'+i))] = entryList
+	}
+	return db
+}
+
+func TestDatabase_AttachDumps_Leaderboards(t *testing.T) {
+	qualifying := newTestDumpSession("server", Qualifying, 1, testDumpTime(12, 20))
+	race := newTestDumpSession("server", Race, 2, testDumpTime(13, 0))
+	sessions := map[string]*Session{"200101_122000_Q": qualifying, "200101_130000_R": race}
+
+	leaderboards := map[string]*Session{
+		"200101_121000_leaderboard":       newTestDumpSession("server", Qualifying, 1, testDumpTime(12, 10)),
+		"200101_123000_leaderboard":       newTestDumpSession("server", Qualifying, 1, testDumpTime(12, 30)),
+		"200101_124000_leaderboard":       newTestDumpSession("server", Race, 2, testDumpTime(12, 40)),
+		"200101_125000_leaderboard":       newTestDumpSession("server", Race, 2, testDumpTime(12, 50)),
+		"200101_140000_leaderboard":       newTestDumpSession("server", Race, 2, testDumpTime(14, 0)),
+		"200101_141000_leaderboard":       newTestDumpSession("server", Race, 2, testDumpTime(14, 10)),
+		"200101_142000_leaderboard":       newTestDumpSession("server", Qualifying, 1, testDumpTime(14, 20)),
+		"other_200101_121000_leaderboard": newTestDumpSession("other", Qualifying, 1, testDumpTime(12, 10)),
+	}
+	db := newTestDumpDatabase(leaderboards)
+
+	intermediate := db.attachDumps(sessions)
+
+	// Leaderboards belong to the first session of the same source ending at or after them, if it is the same session
+	assert.Equal(t, []*Session{leaderboards["200101_121000_leaderboard"]}, qualifying.Leaderboards())
+	assert.Equal(t, []*Session{leaderboards["200101_124000_leaderboard"], leaderboards["200101_125000_leaderboard"]},
+		race.Leaderboards())
+
+	// Other leaderboards form intermediate sessions, merging consecutive leaderboards of the same session
+	names := make([]string, 0, len(intermediate))
+	for name := range intermediate {
+		names = append(names, name)
+	}
+	assert.ElementsMatch(t, []string{"200101_123000_Q", "200101_141000_R", "200101_142000_Q", "other_200101_121000_Q"},
+		names)
+
+	session := intermediate["200101_141000_R"]
+	if assert.NotNil(t, session) {
+		assert.True(t, session.Intermediate)
+		assert.Equal(t, "200101_141000_R", session.SessionName)
+		assert.Equal(t, testDumpTime(14, 10), session.EndTime)
+		assert.Equal(t, []*Session{leaderboards["200101_140000_leaderboard"], leaderboards["200101_141000_leaderboard"]},
+			session.Leaderboards())
+	}
+	if session := intermediate["other_200101_121000_Q"]; assert.NotNil(t, session) {
+		assert.Equal(t, "other", session.Source)
+		assert.Len(t, session.Leaderboards(), 1)
+	}
+
+	// Attaching again replaces the earlier results
+	db.dumps.leaderboards = make(map[string]*Session)
+	assert.Empty(t, db.attachDumps(sessions))
+	assert.Empty(t, qualifying.Leaderboards())
+	assert.Empty(t, race.Leaderboards())
+}
+
+func TestDatabase_AttachDumps_EntryLists(t *testing.T) {
+	qualifying := newTestDumpSession("server", Qualifying, 1, testDumpTime(12, 20))
+	race := newTestDumpSession("server", Race, 2, testDumpTime(13, 0))
+	lonely := newTestDumpSession("server", Race, 2, testDumpTime(15, 0))
+	sessions := map[string]*Session{"200101_122000_Q": qualifying, "200101_130000_R": race, "200101_150000_R": lonely}
+
+	leaderboards := map[string]*Session{
+		"200101_142000_leaderboard": newTestDumpSession("server", Qualifying, 1, testDumpTime(14, 20)),
+	}
+	first := &EntryList{Time: testDumpTime(12, 21), Source: "server"}
+	latest := &EntryList{Time: testDumpTime(12, 22), Source: "server"}
+	afterRace := &EntryList{Time: testDumpTime(13, 4), Source: "server"}
+	tooLate := &EntryList{Time: testDumpTime(13, 6), Source: "server"}
+	otherSource := &EntryList{Time: testDumpTime(15, 1), Source: "other"}
+	forIntermediate := &EntryList{Time: testDumpTime(14, 23), Source: "server"}
+	db := newTestDumpDatabase(leaderboards, first, latest, afterRace, tooLate, otherSource, forIntermediate)
+
+	intermediate := db.attachDumps(sessions)
+
+	// The closest session within the maximum gap gets the latest entry list
+	assert.Same(t, latest, qualifying.EntryList())
+	assert.Same(t, afterRace, race.EntryList())
+	assert.Nil(t, lonely.EntryList())
+	if session := intermediate["200101_142000_Q"]; assert.NotNil(t, session) {
+		assert.Same(t, forIntermediate, session.EntryList())
+	}
+}
+
+func TestDatabase_HasDumpsFor(t *testing.T) {
+	leaderboards := map[string]*Session{
+		"200101_121000_leaderboard":       newTestDumpSession("server", Qualifying, 1, testDumpTime(12, 10)),
+		"200101_124000_leaderboard":       newTestDumpSession("server", Race, 2, testDumpTime(12, 40)),
+		"other_200101_135000_leaderboard": newTestDumpSession("other", Race, 2, testDumpTime(13, 50)),
+	}
+	entryList := &EntryList{Time: testDumpTime(15, 2), Source: "server"}
+	db := newTestDumpDatabase(leaderboards, entryList)
+	db.Sessions = map[string]*Session{
+		"200101_122000_Q": newTestDumpSession("server", Qualifying, 1, testDumpTime(12, 20)),
+	}
+
+	// Leaderboards dumped before the previous session ended stay with that session
+	assert.False(t, db.hasDumpsFor(newTestDumpSession("server", Race, 2, testDumpTime(12, 30))))
+	// Leaderboards dumped since the previous session ended move to the new session
+	assert.True(t, db.hasDumpsFor(newTestDumpSession("server", Race, 2, testDumpTime(13, 0))))
+	assert.True(t, db.hasDumpsFor(newTestDumpSession("server", Race, 2, testDumpTime(12, 40))))
+	// Dumps of other sources are never attached
+	assert.False(t, db.hasDumpsFor(newTestDumpSession("third", Race, 2, testDumpTime(14, 0))))
+	assert.True(t, db.hasDumpsFor(newTestDumpSession("other", Race, 2, testDumpTime(14, 0))))
+
+	// Entry lists are attached to a session ending close to the time they were dumped
+	db.Sessions["200101_130000_R"] = newTestDumpSession("server", Race, 2, testDumpTime(13, 0))
+	assert.False(t, db.hasDumpsFor(newTestDumpSession("server", Race, 2, testDumpTime(14, 0))))
+	assert.True(t, db.hasDumpsFor(newTestDumpSession("server", Race, 2, testDumpTime(15, 0))))
+}
+
+func TestEntryListEntry_ForcedCarModelID(t *testing.T) {
+	for contents, expected := range map[string]int{
+		`{"raceNumber": 1}`:                       -1,
+		`{"raceNumber": 1, "forcedCarModel": -1}`: -1,
+		`{"raceNumber": 1, "forcedCarModel": 0}`:  0,
+		`{"raceNumber": 1, "forcedCarModel": 24}`: 24,
+	} {
+		entry := &EntryListEntry{}
+		if assert.Nil(t, json.Unmarshal([]byte(contents), entry)) {
+			assert.Equal(t, expected, entry.ForcedCarModelID(), contents)
+		}
+	}
+}
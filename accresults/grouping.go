@@ -0,0 +1,156 @@
+package accresults
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const (
+	// GroupBySessionIndex starts a new event at every session with session index 0
+	GroupBySessionIndex = "sessionIndex"
+	// GroupByMetaData starts a new event when the metadata differs from the previous session
+	GroupByMetaData = "metaData"
+	// GroupByRaceWeekendIndex starts a new event when the race weekend index differs from the previous session
+	GroupByRaceWeekendIndex = "raceWeekendIndex"
+	// GroupByServerName starts a new event when the server name differs from the previous session
+	GroupByServerName = "serverName"
+
+	// EventOverrideSplit starts a new event at a session, regardless of the grouping rules
+	EventOverrideSplit = "split"
+	// EventOverrideMerge adds a session to the event of the previous session, regardless of the grouping rules
+	EventOverrideMerge = "merge"
+)
+
+// EventGrouping contains the rules to group sessions into events
+//
+// Sessions are handled in order per source, and every session is added to the event of the previous session unless
+// any of the rules starts a new event. A new event is always started when the track changes.
+type EventGrouping struct {
+	// Rules contains the rules which start a new event; it defaults to the session index rule if it is empty
+	Rules []string `json:"rules"`
+	// MaxGapMinutes starts a new event when a session ended more than this number of minutes after the previous
+	// session; it is not used if it is 0
+	MaxGapMinutes int `json:"maxGapMinutes"`
+}
+
+// validate checks if all rules are known
+func (g *EventGrouping) validate() error {
+	for _, rule := range g.Rules {
+		switch rule {
+		case GroupBySessionIndex, GroupByMetaData, GroupByRaceWeekendIndex, GroupByServerName:
+		default:
+			return fmt.Errorf("unknown event grouping rule '%s'", rule)
+		}
+	}
+	if g.MaxGapMinutes < 0 {
+		return fmt.Errorf("maximum gap between sessions of an event cannot be negative")
+	}
+	return nil
+}
+
+// startsNewEvent checks if a session starts a new event, or belongs to the event of the previous session
+func (g *EventGrouping) startsNewEvent(previous *Session, session *Session) bool {
+	if previous == nil || previous.TrackName != session.TrackName {
+		return true
+	}
+	if g.MaxGapMinutes > 0 && session.EndTime.Sub(previous.EndTime) > time.Duration(g.MaxGapMinutes)*time.Minute {
+		return true
+	}
+
+	rules := g.Rules
+	if len(rules) == 0 {
+		rules = []string{GroupBySessionIndex}
+	}
+	for _, rule := range rules {
+		switch {
+		case rule == GroupBySessionIndex && session.SessionIndex == 0,
+			rule == GroupByMetaData && session.MetaData != previous.MetaData,
+			rule == GroupByRaceWeekendIndex && session.RaceWeekendIndex != previous.RaceWeekendIndex,
+			rule == GroupByServerName && session.ServerName != previous.ServerName:
+			return true
+		}
+	}
+	return false
+}
+
+// eventOverrides contains the manual splits and merges of events, keyed on session name, stored durably on disk
+type eventOverrides struct {
+	path      string
+	overrides map[string]string
+}
+
+// loadEventOverrides loads the overrides from the given file; an empty path keeps them in memory only
+func loadEventOverrides(path string) (*eventOverrides, error) {
+	o := &eventOverrides{
+		path:      path,
+		overrides: make(map[string]string),
+	}
+	if path == "" {
+		return o, nil
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return o, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(contents, &o.overrides); err != nil {
+		return nil, fmt.Errorf("cannot parse event overrides from '%s': %w", path, err)
+	}
+	return o, nil
+}
+
+// set sets the override of a session, or removes it if it is empty
+func (o *eventOverrides) set(sessionName string, override string) {
+	if override == "" {
+		delete(o.overrides, sessionName)
+	} else {
+		o.overrides[sessionName] = override
+	}
+}
+
+func (o *eventOverrides) save() error {
+	if o.path == "" {
+		return nil
+	}
+	contents, err := json.Marshal(o.overrides)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(o.path, contents, 0644)
+}
+
+// EventOverride returns the manual override of the grouping for a session, or an empty string if there is none
+func (db *Database) EventOverride(sessionName string) string {
+	return db.overrides.overrides[sessionName]
+}
+
+// SetEventOverride splits or merges events at a session, or removes the override if it is empty, and regroups all
+// sessions into events. If the overrides cannot be stored, the previous override is kept. The caller must not hold
+// the lock.
+func (db *Database) SetEventOverride(sessionName string, override string) error {
+	switch override {
+	case EventOverrideSplit, EventOverrideMerge, "":
+	default:
+		return fmt.Errorf("unknown event override '%s'", override)
+	}
+
+	db.Mutex.Lock()
+	defer db.Mutex.Unlock()
+
+	if _, ok := db.Sessions[sessionName]; !ok {
+		return fmt.Errorf("unknown session '%s'", sessionName)
+	}
+	previous := db.overrides.overrides[sessionName]
+	db.overrides.set(sessionName, override)
+	if err := db.overrides.save(); err != nil {
+		db.overrides.set(sessionName, previous)
+		return err
+	}
+	db.rebuild()
+	return nil
+}
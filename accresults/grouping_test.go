@@ -0,0 +1,138 @@
+package accresults
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testGroupingStart = time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestGroupingSession creates a session on Monza for the grouping tests
+func newTestGroupingSession(name string, sessionIndex int, endMinutes int) *Session {
+	return &Session{
+		SessionName:  name,
+		TrackName:    "monza",
+		SessionIndex: sessionIndex,
+		MetaData:     "weekend",
+		ServerName:   "Server",
+		EndTime:      testGroupingStart.Add(time.Duration(endMinutes) * time.Minute),
+	}
+}
+
+func TestEventGrouping_StartsNewEvent(t *testing.T) {
+	previous := newTestGroupingSession("P", 0, 0)
+
+	tests := []struct {
+		name     string
+		grouping EventGrouping
+		previous *Session
+		change   func(session *Session)
+		expected bool
+	}{
+		{"first session", EventGrouping{}, nil, func(s *Session) { s.SessionIndex = 1 }, true},
+		{"next session index", EventGrouping{}, previous, func(s *Session) { s.SessionIndex = 1 }, false},
+		{"session index 0", EventGrouping{}, previous, func(s *Session) {}, true},
+		{"track changed", EventGrouping{}, previous, func(s *Session) { s.SessionIndex = 1; s.TrackName = "spa" }, true},
+		{"session index rule", EventGrouping{Rules: []string{GroupBySessionIndex}}, previous,
+			func(s *Session) {}, true},
+		{"same metadata", EventGrouping{Rules: []string{GroupByMetaData}}, previous, func(s *Session) {}, false},
+		{"other metadata", EventGrouping{Rules: []string{GroupByMetaData}}, previous,
+			func(s *Session) { s.MetaData = "other" }, true},
+		{"same race weekend", EventGrouping{Rules: []string{GroupByRaceWeekendIndex}}, previous,
+			func(s *Session) {}, false},
+		{"other race weekend", EventGrouping{Rules: []string{GroupByRaceWeekendIndex}}, previous,
+			func(s *Session) { s.RaceWeekendIndex = 1 }, true},
+		{"same server name", EventGrouping{Rules: []string{GroupByServerName}}, previous, func(s *Session) {}, false},
+		{"other server name", EventGrouping{Rules: []string{GroupByServerName}}, previous,
+			func(s *Session) { s.ServerName = "Other" }, true},
+		{"any rule", EventGrouping{Rules: []string{GroupByMetaData, GroupByServerName}}, previous,
+			func(s *Session) { s.ServerName = "Other" }, true},
+		{"track changed without rules matching", EventGrouping{Rules: []string{GroupByMetaData}}, previous,
+			func(s *Session) { s.TrackName = "spa" }, true},
+		{"within maximum gap", EventGrouping{MaxGapMinutes: 60}, previous,
+			func(s *Session) { s.SessionIndex = 1; s.EndTime = testGroupingStart.Add(60 * time.Minute) }, false},
+		{"beyond maximum gap", EventGrouping{MaxGapMinutes: 60}, previous,
+			func(s *Session) { s.SessionIndex = 1; s.EndTime = testGroupingStart.Add(61 * time.Minute) }, true},
+		{"no maximum gap", EventGrouping{}, previous,
+			func(s *Session) { s.SessionIndex = 1; s.EndTime = testGroupingStart.Add(48 * time.Hour) }, false},
+	}
+
+	for _, test := range tests {
+		session := newTestGroupingSession("S", 0, 30)
+		test.change(session)
+		assert.Equal(t, test.expected, test.grouping.startsNewEvent(test.previous, session), test.name)
+	}
+}
+
+func TestEventGrouping_Validate(t *testing.T) {
+	assert.Nil(t, (&EventGrouping{}).validate())
+	assert.Nil(t, (&EventGrouping{Rules: []string{GroupBySessionIndex, GroupByMetaData, GroupByRaceWeekendIndex,
+		GroupByServerName}, MaxGapMinutes: 10}).validate())
+	assert.NotNil(t, (&EventGrouping{Rules: []string{"unknown"}}).validate())
+	assert.NotNil(t, (&EventGrouping{MaxGapMinutes: -1}).validate())
+}
+
+func TestDatabase_StartsNewEvent(t *testing.T) {
+	previous := newTestGroupingSession("P", 0, 0)
+	lastEvent := &Event{TrackName: "monza", Sessions: []*Session{previous}}
+
+	tests := []struct {
+		name      string
+		override  string
+		lastEvent *Event
+		change    func(session *Session)
+		expected  bool
+	}{
+		{"grouping rules", "", lastEvent, func(s *Session) { s.SessionIndex = 1 }, false},
+		{"split", EventOverrideSplit, lastEvent, func(s *Session) { s.SessionIndex = 1 }, true},
+		{"merge", EventOverrideMerge, lastEvent, func(s *Session) {}, false},
+		{"merge beyond maximum gap", EventOverrideMerge, lastEvent,
+			func(s *Session) { s.EndTime = testGroupingStart.Add(2 * time.Hour) }, false},
+		{"merge without previous event", EventOverrideMerge, nil, func(s *Session) {}, true},
+		{"merge across track change", EventOverrideMerge, lastEvent, func(s *Session) { s.TrackName = "spa" }, true},
+	}
+
+	for _, test := range tests {
+		session := newTestGroupingSession("S", 0, 30)
+		test.change(session)
+		db := &Database{
+			grouping:  EventGrouping{MaxGapMinutes: 60},
+			overrides: &eventOverrides{overrides: map[string]string{}},
+		}
+		if test.override != "" {
+			db.overrides.overrides[session.SessionName] = test.override
+		}
+		assert.Equal(t, test.expected, db.startsNewEvent(test.lastEvent, session), test.name)
+	}
+}
+
+func TestDatabase_SetEventOverride(t *testing.T) {
+	first := newTestGroupingSession("200101_120000_R", 0, 0)
+	second := newTestGroupingSession("200101_130000_R", 1, 60)
+	first.SessionResult = &SessionResult{}
+	second.SessionResult = &SessionResult{}
+	db := &Database{
+		Mutex:     &sync.RWMutex{},
+		Sessions:  map[string]*Session{first.SessionName: first, second.SessionName: second},
+		overrides: &eventOverrides{overrides: map[string]string{}},
+		dumps:     newDumps(),
+	}
+
+	assert.Nil(t, db.SetEventOverride(second.SessionName, EventOverrideSplit))
+	assert.Equal(t, EventOverrideSplit, db.EventOverride(second.SessionName))
+	assert.Len(t, db.Events, 2)
+
+	// The previous override is kept if the overrides cannot be stored
+	db.overrides.path = t.Name() + "/missing/event-overrides.json"
+	assert.NotNil(t, db.SetEventOverride(second.SessionName, ""))
+	assert.Equal(t, EventOverrideSplit, db.EventOverride(second.SessionName))
+	assert.NotNil(t, db.SetEventOverride(first.SessionName, EventOverrideMerge))
+	assert.Equal(t, "", db.EventOverride(first.SessionName))
+	assert.Len(t, db.Events, 2)
+
+	assert.NotNil(t, db.SetEventOverride("unknown", EventOverrideSplit))
+	assert.NotNil(t, db.SetEventOverride(first.SessionName, "unknown"))
+}
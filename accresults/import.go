@@ -0,0 +1,33 @@
+package accresults
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ImportSessionFile stores a session results file in the directory of a source, where it is picked up like any other
+// new results file. Existing files are never overwritten.
+func (db *Database) ImportSessionFile(sourceName string, fileName string, contents []byte) error {
+	if filepath.Base(fileName) != fileName || !IsSessionFile(fileName) {
+		return fmt.Errorf("'%s' is not the name of a session results file", fileName)
+	}
+
+	for _, source := range db.sources {
+		if source.Name != sourceName {
+			continue
+		}
+		file, err := os.OpenFile(source.dir()+fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			return fmt.Errorf("'%s' already exists in source '%s'", fileName, sourceName)
+		} else if err != nil {
+			return err
+		}
+		if _, err := file.Write(contents); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}
+	return fmt.Errorf("unknown results source '%s'", sourceName)
+}
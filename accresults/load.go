@@ -0,0 +1,177 @@
+package accresults
+
+import (
+	"log"
+	"runtime"
+	"sync"
+)
+
+// LoadProgress describes how far loading the results files from disk has progressed
+type LoadProgress struct {
+	// FilesTotal is the number of results files to load
+	FilesTotal int
+	// FilesDone is the number of results files which have been loaded or failed to load
+	FilesDone int
+	// Errors is the number of results files which could not be loaded
+	Errors int
+	// Loaded indicates all results files have been loaded
+	Loaded bool
+}
+
+// Percent returns the percentage of files which are done
+func (p LoadProgress) Percent() int {
+	if p.FilesTotal == 0 {
+		return 100
+	}
+	return 100 * p.FilesDone / p.FilesTotal
+}
+
+// loadProgress keeps track of the progress while loading, which can be read concurrently
+type loadProgress struct {
+	mutex    sync.Mutex
+	progress LoadProgress
+}
+
+func (p *loadProgress) start(filesTotal int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.progress.FilesTotal = filesTotal
+}
+
+func (p *loadProgress) fileDone(err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.progress.FilesDone++
+	if err != nil {
+		p.progress.Errors++
+	}
+}
+
+func (p *loadProgress) finish() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.progress.Loaded = true
+}
+
+func (p *loadProgress) get() LoadProgress {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.progress
+}
+
+// LoadProgress returns how far loading the results files from disk has progressed
+func (db *Database) LoadProgress() LoadProgress {
+	return db.progress.get()
+}
+
+// parseResult is the outcome of parsing a single results file
+type parseResult struct {
+	index   int
+	session *Session
+	err     error
+}
+
+// parseFiles parses the files with the given indices using a pool of workers, and sends the results on a channel
+func parseFiles(files []*sourceFile, indices []int) <-chan *parseResult {
+	jobs := make(chan int)
+	results := make(chan *parseResult)
+
+	go func() {
+		for _, i := range indices {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	for w := 0; w < runtime.NumCPU(); w++ {
+		go func() {
+			for i := range jobs {
+				session, err := parseSessionFile(files[i].source, files[i].info.Name())
+				results <- &parseResult{i, session, err}
+			}
+		}()
+	}
+
+	return results
+}
+
+// load loads all given session files into the database
+//
+// Files are parsed concurrently, but sessions are added in the order of the files, because events are resolved
+// based on the previously added session of the same source. Every session is added as soon as all files before it
+// are done, so the database fills up while loading.
+func (db *Database) load(config *Configuration, files []*sourceFile) {
+	db.progress.start(len(files))
+
+	// Dump files are few and small, so they are loaded up front; they are attached once all sessions are loaded
+	sessionFiles := make([]*sourceFile, 0, len(files))
+	for _, file := range files {
+		if !isDumpFile(file.info.Name()) {
+			sessionFiles = append(sessionFiles, file)
+			continue
+		}
+		err := db.addDumpFile(file.source, file.info.Name())
+		if err != nil {
+			log.Printf("Error loading dump file '%v': %v", file.info.Name(), err)
+			db.failed.add(file.source, file.info.Name(), err)
+		}
+		db.progress.fileDone(err)
+	}
+	files = sessionFiles
+
+	cache := loadParseCache(config.CachePath, config.Options)
+	sessions := make([]*Session, len(files))
+	errs := make([]error, len(files))
+	done := make([]bool, len(files))
+	misses := make([]int, 0)
+	for i, file := range files {
+		if sessions[i] = cache.lookup(file.sessionName(), file.info); sessions[i] != nil {
+			done[i] = true
+		} else {
+			misses = append(misses, i)
+		}
+	}
+
+	next := 0
+	addDoneSessions := func() {
+		for ; next < len(files) && done[next]; next++ {
+			if errs[next] != nil {
+				log.Printf("Error loading session results file '%v': %v", files[next].info.Name(), errs[next])
+				db.failed.add(files[next].source, files[next].info.Name(), errs[next])
+			} else {
+				db.addParsedSession(files[next].sessionName(), sessions[next])
+			}
+			// The session has been handed over; the parse cache keeps its own reference until it is saved
+			sessions[next] = nil
+			db.progress.fileDone(errs[next])
+		}
+	}
+
+	addDoneSessions()
+	results := parseFiles(files, misses)
+	for range misses {
+		result := <-results
+		sessions[result.index] = result.session
+		errs[result.index] = result.err
+		done[result.index] = true
+		if result.err == nil {
+			file := files[result.index]
+			cache.store(file.sessionName(), file.info, result.session)
+		}
+		addDoneSessions()
+	}
+
+	if err := cache.save(); err != nil {
+		log.Printf("Cannot save results cache '%s': %v", config.CachePath, err)
+	}
+
+	db.Mutex.Lock()
+	if !db.dumps.isEmpty() {
+		db.rebuild()
+	}
+	db.Mutex.Unlock()
+	db.progress.finish()
+
+	progress := db.progress.get()
+	log.Printf("Loaded %d results files, of which %d failed", progress.FilesDone, progress.Errors)
+}
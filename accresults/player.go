@@ -141,3 +141,18 @@ func (player *Player) addCarInSession(car *Car, session *Session, event *Event)
 	player.Events[event.EventId] = event
 	player.addTrackDataForCarInSession(car, session)
 }
+
+// bestLap returns the fastest lap of a player on a track, optionally limited to a car model (-1 for all)
+func (player *Player) bestLap(trackName string, carModel int) int {
+	trackData := player.TrackData[trackName]
+	if trackData == nil {
+		return math.MaxInt32
+	}
+	if carModel < 0 {
+		return trackData.BestLap
+	}
+	if carData := trackData.CarData[carModel]; carData != nil {
+		return carData.BestLap
+	}
+	return math.MaxInt32
+}
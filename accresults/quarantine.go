@@ -0,0 +1,100 @@
+package accresults
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FailedFile is a session results file which could not be loaded
+type FailedFile struct {
+	// Source is the name of the source containing the file
+	Source string
+	// FileName is the name of the file within the source
+	FileName string
+	// Error describes why the file could not be loaded
+	Error string
+	// Time is when loading the file failed most recently
+	Time time.Time
+}
+
+// quarantine keeps all session results files which could not be loaded, keyed on session name
+type quarantine struct {
+	mutex sync.Mutex
+	files map[string]*FailedFile
+}
+
+func newQuarantine() *quarantine {
+	return &quarantine{files: make(map[string]*FailedFile)}
+}
+
+func (q *quarantine) add(source *Source, fileName string, err error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.files[source.sessionName(fileName)] = &FailedFile{source.Name, fileName, err.Error(), time.Now()}
+}
+
+func (q *quarantine) remove(source *Source, fileName string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	delete(q.files, source.sessionName(fileName))
+}
+
+// FailedFiles returns all session results files which could not be loaded, sorted on source and file name
+func (db *Database) FailedFiles() []*FailedFile {
+	db.failed.mutex.Lock()
+	defer db.failed.mutex.Unlock()
+
+	files := make([]*FailedFile, 0, len(db.failed.files))
+	for _, file := range db.failed.files {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Source != files[j].Source {
+			return files[i].Source < files[j].Source
+		}
+		return files[i].FileName < files[j].FileName
+	})
+	return files
+}
+
+// FailedFileCount returns the number of session results files which could not be loaded
+func (db *Database) FailedFileCount() int {
+	db.failed.mutex.Lock()
+	defer db.failed.mutex.Unlock()
+	return len(db.failed.files)
+}
+
+// isFailed checks if a file of a source is currently in the quarantine
+func (q *quarantine) isFailed(source *Source, fileName string) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	failed, ok := q.files[source.sessionName(fileName)]
+	return ok && failed.Source == source.Name && failed.FileName == fileName
+}
+
+// RetryFailedFile tries to load a session results file which could not be loaded before. It returns an error if the
+// file still cannot be loaded, or if it is not a file which failed to load.
+func (db *Database) RetryFailedFile(sourceName string, fileName string) error {
+	if filepath.Base(fileName) != fileName || !isResultsFile(fileName) {
+		return fmt.Errorf("'%s' is not a failed file", fileName)
+	}
+
+	for _, source := range db.sources {
+		if source.Name == sourceName {
+			if !db.failed.isFailed(source, fileName) {
+				return fmt.Errorf("'%s' is not a failed file", fileName)
+			}
+			db.loadResultsFile(source, fileName)
+			db.failed.mutex.Lock()
+			defer db.failed.mutex.Unlock()
+			if failed, ok := db.failed.files[source.sessionName(fileName)]; ok {
+				return fmt.Errorf("%s", failed.Error)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown results source '%s'", sourceName)
+}
@@ -21,13 +21,15 @@ const (
 
 // TODO: enum CarModel
 // TODO: enum CupCategory
-// TODO: enum Nationality
 
 type Driver struct {
 	FirstName string `json:"firstName"`
 	LastName  string `json:"lastName"`
 	ShortName string `json:"shortName"`
 	PlayerId  string `json:"playerId"`
+	// Nationality and DriverCategory are only written by recent versions of accServer; they are 0 otherwise
+	Nationality    int `json:"nationality"`
+	DriverCategory int `json:"driverCategory"`
 }
 
 type Car struct {
@@ -40,6 +42,10 @@ type Car struct {
 	CarGuid     int       `json:"carGuid"`
 	TeamGuid    int       `json:"teamGuid"`
 	Drivers     []*Driver `json:"drivers"`
+	// CarGroup, BallastKg and Restrictor are only written by recent versions of accServer; they are empty otherwise
+	CarGroup   string `json:"carGroup"`
+	BallastKg  int    `json:"ballastKg"`
+	Restrictor int    `json:"restrictor"`
 }
 
 type LeaderBoardTiming struct {
@@ -58,11 +64,32 @@ type LeaderBoardLine struct {
 	CurrentDriver           *Driver            `json:"currentDriver"`
 	CurrentDriverIndex      int                `json:"currentDriverIndex"`
 	Timing                  *LeaderBoardTiming `json:"timing"`
-	MissingMandatoryPitstop int                `json:"missingMandatoryPitstop`
+	MissingMandatoryPitstop int                `json:"missingMandatoryPitstop"`
 	//    DriverTotalTimes []*time.Duration `json:"driverTotalTimes"`
 	DriverTotalTimes []float64 `json:"driverTotalTimes"`
 }
 
+// DriverTime is the time a driver spent driving a car during a session
+type DriverTime struct {
+	Driver *Driver
+	// TotalTimeMS is the total time driven in milliseconds
+	TotalTimeMS int
+}
+
+// DriverTimes returns the time driven by each driver of the car. The time is 0 for drivers without a time in the
+// results file.
+func (l *LeaderBoardLine) DriverTimes() []*DriverTime {
+	times := make([]*DriverTime, 0, len(l.Car.Drivers))
+	for i, driver := range l.Car.Drivers {
+		time := &DriverTime{Driver: driver}
+		if i < len(l.DriverTotalTimes) {
+			time.TotalTimeMS = int(l.DriverTotalTimes[i])
+		}
+		times = append(times, time)
+	}
+	return times
+}
+
 // Verify checks if the leaderboard line is fully filled
 func (l *LeaderBoardLine) Verify() error {
 	if l.Car == nil {
@@ -122,10 +149,22 @@ type Session struct {
 	Laps              []*Lap         `json:"laps"`
 	Penalties         []*Penalty     `json:"penalties"`
 	PostRacePenalties []*Penalty     `json:"post_race_penalties"`
+	// Reconstructed indicates the results were reconstructed by racce from the server log, because accServer did
+	// not write a results file for the session
+	Reconstructed bool `json:"racceReconstructed,omitempty"`
+	// Intermediate indicates accServer did not write a results file for the session, so it is shown from the last
+	// intermediate leaderboard dumped during the session
+	Intermediate bool `json:"-"`
 
 	SessionName       string
 	EndTime           time.Time
 	SessionTypeString string
+	// Source is the name of the source of the results file
+	Source string
+
+	// entryList and leaderboards are attached from the dump files when the database is rebuilt
+	entryList    *EntryList
+	leaderboards []*Session
 }
 
 // Verify checks if the session is fully filled
@@ -162,9 +201,17 @@ func (session *Session) calculateCarPositionsPerLap() {
 }
 
 func (session *Session) FindCarById(carId int) *Car {
+	if line := session.FindLineByCarId(carId); line != nil {
+		return line.Car
+	}
+	return nil
+}
+
+// FindLineByCarId returns the leaderboard line of a car, or nil if the car is not in the session
+func (session *Session) FindLineByCarId(carId int) *LeaderBoardLine {
 	for _, line := range session.SessionResult.LeaderBoardLines {
 		if line.Car.CarId == carId {
-			return line.Car
+			return line
 		}
 	}
 	return nil
@@ -0,0 +1,82 @@
+package accresults
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// sourceNamePattern matches valid source names; they start with a letter, so sessions of different sources can never
+// have the same name
+var sourceNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*$`)
+
+// Source is a named directory containing session results files
+type Source struct {
+	// Name identifies the source in the frontend; it must consist of letters, digits and dashes, starting with a letter
+	Name string `json:"name"`
+	// Dir is the directory which contains the results files
+	Dir string `json:"dir"`
+
+	// primary indicates the session names of this source are not prefixed
+	primary bool
+}
+
+// NewPrimarySource creates a source whose session names are not prefixed with its name, so existing links to its
+// sessions keep working; at most one source can be primary
+func NewPrimarySource(name, dir string) *Source {
+	return &Source{Name: name, Dir: dir, primary: true}
+}
+
+// dir returns the Dir with a single slash at the end
+func (s *Source) dir() string {
+	return strings.TrimRight(s.Dir, "/") + "/"
+}
+
+// sessionName returns the name of the session in a session results file of this source
+//
+// Sessions of the primary source are named after their file, so existing links keep working. Sessions of all other
+// sources are prefixed with the name of the source, to keep names unique across sources.
+func (s *Source) sessionName(fileName string) string {
+	baseName := strings.TrimSuffix(fileName, ".json")
+	if s.primary {
+		return baseName
+	}
+	return s.Name + "_" + baseName
+}
+
+// validateSources checks if all sources have a valid and unique name, and if at most one of them is primary
+func validateSources(sources []*Source) error {
+	if len(sources) == 0 {
+		return fmt.Errorf("no results sources")
+	}
+	names := make(map[string]bool)
+	primary := ""
+	for _, source := range sources {
+		if !sourceNamePattern.MatchString(source.Name) {
+			return fmt.Errorf("invalid name '%s' for results source", source.Name)
+		}
+		if names[source.Name] {
+			return fmt.Errorf("duplicate results source '%s'", source.Name)
+		}
+		names[source.Name] = true
+		if source.primary {
+			if primary != "" {
+				return fmt.Errorf("results sources '%s' and '%s' are both primary", primary, source.Name)
+			}
+			primary = source.Name
+		}
+	}
+	return nil
+}
+
+// sourceFile is a session results file in a source
+type sourceFile struct {
+	source *Source
+	info   os.FileInfo
+}
+
+// sessionName returns the name of the session in the file
+func (f *sourceFile) sessionName() string {
+	return f.source.sessionName(f.info.Name())
+}
@@ -0,0 +1,234 @@
+package accresults
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// defaultPollInterval is the time between scans of the results dir when falling back to polling
+	defaultPollInterval = 30 * time.Second
+	// watcherRestartDelay is the time to wait before restarting a failed watcher
+	watcherRestartDelay = 10 * time.Second
+)
+
+// fileState contains the properties of a file used to detect changes
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+func newFileState(info os.FileInfo) fileState {
+	return fileState{info.Size(), info.ModTime()}
+}
+
+func (s fileState) equals(other fileState) bool {
+	return s.size == other.size && s.modTime.Equal(other.modTime)
+}
+
+// pendingLoad is a file which is going to be loaded once its timer expires
+type pendingLoad struct {
+	fileName string
+	timer    *time.Timer
+}
+
+// resultsDirMonitor keeps the database in sync with the session and dump files in the directory of a source
+//
+// File system events are used to detect changes, unless polling is configured. If the watcher cannot be started, it
+// falls back to polling. If a running watcher fails, it is restarted. Every time the watcher starts, the results dir
+// is scanned to pick up changes which were missed while it was not running.
+type resultsDirMonitor struct {
+	db     *Database
+	config *Configuration
+	source *Source
+	// files contains the state of all session and dump files as last seen
+	files map[string]fileState
+	// pendingLoads contains a timer per file which is going to be loaded, which is restarted on every change to the
+	// file so it is only loaded once it is complete
+	pendingLoads map[string]*pendingLoad
+	// expiredLoads receives the pending loads whose timer expired, so they are loaded by the monitor loop itself
+	expiredLoads chan *pendingLoad
+}
+
+// newResultsDirMonitor creates a monitor for the directory of a source, starting from the given session files which
+// are already loaded into the database
+func newResultsDirMonitor(db *Database, config *Configuration, source *Source, files []os.FileInfo) *resultsDirMonitor {
+	m := &resultsDirMonitor{
+		db:           db,
+		config:       config,
+		source:       source,
+		files:        make(map[string]fileState),
+		pendingLoads: make(map[string]*pendingLoad),
+		expiredLoads: make(chan *pendingLoad),
+	}
+	for _, info := range files {
+		m.files[info.Name()] = newFileState(info)
+	}
+	return m
+}
+
+// run monitors the results dir forever
+func (m *resultsDirMonitor) run() {
+	for m.config.PollInterval <= 0 {
+		started, err := m.watch()
+		if !started {
+			log.Printf("Cannot watch results dir '%s', falling back to polling every %v: %v", m.source.Dir, defaultPollInterval, err)
+			break
+		}
+		log.Printf("Watcher for results dir '%s' failed, restarting in %v: %v", m.source.Dir, watcherRestartDelay, err)
+		time.Sleep(watcherRestartDelay)
+	}
+	m.poll()
+}
+
+// watch handles file system events until the watcher fails. It returns whether the watcher was started at all.
+func (m *resultsDirMonitor) watch() (bool, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := watcher.Close(); err != nil {
+			log.Printf("Error closing results dir watcher: %v", err)
+		}
+	}()
+
+	if err := watcher.Add(m.source.dir()); err != nil {
+		return false, err
+	}
+	m.scan()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return true, errors.New("watcher was closed")
+			}
+			m.handleEvent(event)
+		case load := <-m.expiredLoads:
+			m.load(load)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return true, errors.New("watcher was closed")
+			}
+			return true, err
+		}
+	}
+}
+
+// poll scans the results dir at a fixed interval forever
+func (m *resultsDirMonitor) poll() {
+	interval := time.Duration(m.config.PollInterval) * time.Second
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.scan()
+		for waiting := true; waiting; {
+			select {
+			case <-ticker.C:
+				waiting = false
+			case load := <-m.expiredLoads:
+				m.load(load)
+			}
+		}
+	}
+}
+
+// handleEvent handles a single file system event
+func (m *resultsDirMonitor) handleEvent(event fsnotify.Event) {
+	fileName := filepath.Base(event.Name)
+	if !isResultsFile(fileName) {
+		if event.Op&fsnotify.Create == fsnotify.Create {
+			log.Printf("Ignoring file '%s' because it is not a results file", fileName)
+		}
+		return
+	}
+
+	if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		m.fileRemoved(fileName)
+	} else if event.Op&(fsnotify.Create|fsnotify.Write) != 0 {
+		info, err := os.Stat(event.Name)
+		if err != nil {
+			m.fileRemoved(fileName)
+		} else {
+			m.fileChanged(info)
+		}
+	}
+}
+
+// scan compares the session and dump files in the results dir with the files as last seen, and handles all differences
+func (m *resultsDirMonitor) scan() {
+	infos, err := ioutil.ReadDir(m.source.dir())
+	if err != nil {
+		log.Printf("Cannot scan results dir '%s': %v", m.source.Dir, err)
+		return
+	}
+
+	present := make(map[string]bool)
+	for _, info := range infos {
+		if !isResultsFile(info.Name()) {
+			continue
+		}
+		present[info.Name()] = true
+		if state, ok := m.files[info.Name()]; !ok || !state.equals(newFileState(info)) {
+			m.fileChanged(info)
+		}
+	}
+
+	for fileName := range m.files {
+		if !present[fileName] {
+			m.fileRemoved(fileName)
+		}
+	}
+}
+
+// fileChanged schedules loading a new or modified session or dump file
+func (m *resultsDirMonitor) fileChanged(info os.FileInfo) {
+	fileName := info.Name()
+	m.files[fileName] = newFileState(info)
+
+	delay := time.Duration(m.config.NewFileDelay) * time.Second
+	if load, ok := m.pendingLoads[fileName]; ok && load.timer.Stop() {
+		load.timer.Reset(delay)
+		return
+	}
+	// A timer which already expired is replaced, so its load is skipped and the file is loaded once it is complete
+	load := &pendingLoad{fileName: fileName}
+	m.pendingLoads[fileName] = load
+	load.timer = time.AfterFunc(delay, func() {
+		m.expiredLoads <- load
+	})
+}
+
+// load loads a file whose timer expired, unless it was changed or removed again in the meantime
+func (m *resultsDirMonitor) load(load *pendingLoad) {
+	if m.pendingLoads[load.fileName] != load {
+		return
+	}
+	delete(m.pendingLoads, load.fileName)
+
+	log.Printf("Loading new or modified results file '%s'", load.fileName)
+	m.db.loadResultsFile(m.source, load.fileName)
+}
+
+// fileRemoved removes the contents of a session or dump file which no longer exists
+func (m *resultsDirMonitor) fileRemoved(fileName string) {
+	if load, ok := m.pendingLoads[fileName]; ok {
+		load.timer.Stop()
+		delete(m.pendingLoads, fileName)
+	}
+	delete(m.files, fileName)
+
+	log.Printf("Removing results file '%s'", fileName)
+	m.db.removeResultsFile(m.source, fileName)
+}
@@ -0,0 +1,176 @@
+package accserver
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/geniusdex/racce/accresults"
+)
+
+const (
+	// maxBallastKg and maxRestrictor are the highest values accepted by accServer
+	maxBallastKg  = 100
+	maxRestrictor = 20
+)
+
+// SuccessBallastRules specifies how ballast and restrictor are derived from the results of previous races
+type SuccessBallastRules struct {
+	// Sessions is the number of most recent races taken into account
+	Sessions int `json:"sessions"`
+	// BallastKg is the ballast per finishing position, starting at P1
+	BallastKg []int `json:"ballastKg"`
+	// Restrictor is the restrictor percentage per finishing position, starting at P1
+	Restrictor []int `json:"restrictor"`
+	// MaxBallastKg caps the total ballast of a driver; it defaults to the maximum of accServer
+	MaxBallastKg int `json:"maxBallastKg"`
+	// MaxRestrictor caps the total restrictor of a driver; it defaults to the maximum of accServer
+	MaxRestrictor int `json:"maxRestrictor"`
+	// Decay is the fraction of the ballast and restrictor which is lost for every race that is more recent
+	Decay float64 `json:"decay"`
+}
+
+// validate checks if the rules can be used
+func (r *SuccessBallastRules) validate() error {
+	if r.Sessions <= 0 {
+		return fmt.Errorf("number of sessions for success ballast must be positive")
+	}
+	if r.Decay < 0 || r.Decay >= 1 {
+		return fmt.Errorf("decay of success ballast must be at least 0 and less than 1")
+	}
+	return nil
+}
+
+// DecayPercent returns the decay as a percentage
+func (r *SuccessBallastRules) DecayPercent() float64 {
+	return r.Decay * 100
+}
+
+// capped returns the cap for a value, using the maximum of accServer if no cap is configured
+func capped(value float64, limit int, serverMax int) int {
+	if limit <= 0 || limit > serverMax {
+		limit = serverMax
+	}
+	rounded := int(math.Round(value))
+	if rounded > limit {
+		return limit
+	}
+	return rounded
+}
+
+// valueForPosition returns the value for the 0-based finishing position, or 0 if none is configured
+func valueForPosition(values []int, position int) int {
+	if position < len(values) {
+		return values[position]
+	}
+	return 0
+}
+
+// DriverBallast is the success ballast computed for a single driver
+type DriverBallast struct {
+	PlayerID   string
+	Driver     *accresults.Driver
+	Car        *accresults.Car
+	BallastKg  int
+	Restrictor int
+}
+
+// ComputeSuccessBallast computes the ballast and restrictor for every driver in the given races, which must be
+// ordered most recent first. Drivers are returned with the highest ballast first.
+func ComputeSuccessBallast(rules *SuccessBallastRules, races []*accresults.Session) ([]*DriverBallast, error) {
+	if err := rules.validate(); err != nil {
+		return nil, err
+	}
+
+	ballast := make(map[string]float64)
+	restrictor := make(map[string]float64)
+	drivers := make(map[string]*DriverBallast)
+	order := make([]string, 0)
+	age := 0
+	for _, race := range races {
+		if age >= rules.Sessions {
+			break
+		}
+		if race.SessionType != accresults.Race || race.SessionResult == nil {
+			continue
+		}
+		factor := math.Pow(1-rules.Decay, float64(age))
+		for position, line := range race.SessionResult.LeaderBoardLines {
+			for _, driver := range line.Car.Drivers {
+				if _, ok := drivers[driver.PlayerId]; !ok {
+					// The most recent car and name of the driver are used
+					drivers[driver.PlayerId] = &DriverBallast{PlayerID: driver.PlayerId, Driver: driver, Car: line.Car}
+					order = append(order, driver.PlayerId)
+				}
+				ballast[driver.PlayerId] += factor * float64(valueForPosition(rules.BallastKg, position))
+				restrictor[driver.PlayerId] += factor * float64(valueForPosition(rules.Restrictor, position))
+			}
+		}
+		age++
+	}
+
+	result := make([]*DriverBallast, 0, len(order))
+	for _, playerID := range order {
+		driver := drivers[playerID]
+		driver.BallastKg = capped(ballast[playerID], rules.MaxBallastKg, maxBallastKg)
+		driver.Restrictor = capped(restrictor[playerID], rules.MaxRestrictor, maxRestrictor)
+		result = append(result, driver)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].BallastKg != result[j].BallastKg {
+			return result[i].BallastKg > result[j].BallastKg
+		}
+		return result[i].Restrictor > result[j].Restrictor
+	})
+	return result, nil
+}
+
+// findEntryForPlayer returns the entry containing the player, or nil if there is none
+func (l *CfgEntryList) findEntryForPlayer(playerID string) *CfgEntryListEntry {
+	for _, entry := range l.Entries {
+		for _, driver := range entry.Drivers {
+			if driver.PlayerID == playerID {
+				return entry
+			}
+		}
+	}
+	return nil
+}
+
+// WithBallast returns a copy of the entry list with the ballast and restrictor of every entry set to the highest
+// values of its drivers. Drivers with ballast or restrictor which are not in the entry list yet are added with their
+// most recent car; entries without any driver in the given list get no ballast or restrictor.
+func (l *CfgEntryList) WithBallast(drivers []*DriverBallast) *CfgEntryList {
+	result := &CfgEntryList{
+		Entries:  make([]*CfgEntryListEntry, 0, len(drivers)),
+		modified: true,
+	}
+	if l != nil {
+		result.ForceEntryList = l.ForceEntryList
+		result.ConfigVersion = l.ConfigVersion
+		for _, entry := range l.Entries {
+			copied := *entry
+			copied.BallastKg = 0
+			copied.Restrictor = 0
+			result.Entries = append(result.Entries, &copied)
+		}
+	}
+
+	for _, driver := range drivers {
+		entry := result.findEntryForPlayer(driver.PlayerID)
+		if entry == nil {
+			if driver.BallastKg == 0 && driver.Restrictor == 0 {
+				continue
+			}
+			entry = newEntryForCar(driver.Car)
+			result.Entries = append(result.Entries, entry)
+		}
+		if driver.BallastKg > entry.BallastKg {
+			entry.BallastKg = driver.BallastKg
+		}
+		if driver.Restrictor > entry.Restrictor {
+			entry.Restrictor = driver.Restrictor
+		}
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+package accserver
+
+import (
+	"testing"
+
+	"github.com/geniusdex/racce/accresults"
+	"github.com/stretchr/testify/assert"
+)
+
+func ballastPerPlayer(drivers []*DriverBallast) map[string][2]int {
+	result := make(map[string][2]int)
+	for _, driver := range drivers {
+		result[driver.PlayerID] = [2]int{driver.BallastKg, driver.Restrictor}
+	}
+	return result
+}
+
+func TestComputeSuccessBallast(t *testing.T) {
+	rules := &SuccessBallastRules{
+		Sessions:   2,
+		BallastKg:  []int{30, 20, 10},
+		Restrictor: []int{4, 2},
+	}
+	races := []*accresults.Session{
+		newTestGridSession(accresults.Race, newTestGridCar(1, "S1"), newTestGridCar(2, "S2", "S5"), newTestGridCar(3, "S3")),
+		newTestGridSession(accresults.Qualifying, newTestGridCar(4, "S4")),
+		newTestGridSession(accresults.Race, newTestGridCar(1, "S1"), newTestGridCar(3, "S3"), newTestGridCar(4, "S4"), newTestGridCar(2, "S2")),
+		newTestGridSession(accresults.Race, newTestGridCar(4, "S4")),
+	}
+
+	drivers, err := ComputeSuccessBallast(rules, races)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][2]int{
+		"S1": {60, 8},
+		"S2": {20, 2},
+		"S5": {20, 2},
+		"S3": {30, 2},
+		"S4": {10, 0},
+	}, ballastPerPlayer(drivers))
+	assert.Equal(t, "S1", drivers[0].PlayerID)
+	assert.Equal(t, "S3", drivers[1].PlayerID)
+}
+
+func TestComputeSuccessBallast_CapsAndDecay(t *testing.T) {
+	rules := &SuccessBallastRules{
+		Sessions:      3,
+		BallastKg:     []int{40},
+		Restrictor:    []int{10},
+		MaxBallastKg:  60,
+		MaxRestrictor: 0,
+		Decay:         0.5,
+	}
+	races := []*accresults.Session{
+		newTestGridSession(accresults.Race, newTestGridCar(1, "S1")),
+		newTestGridSession(accresults.Race, newTestGridCar(1, "S1")),
+		newTestGridSession(accresults.Race, newTestGridCar(1, "S1"), newTestGridCar(2, "S2")),
+	}
+
+	// 40 + 20 + 10 = 70 kg capped at 60, and 10 + 5 + 2.5 = 17.5% rounded to 18
+	drivers, err := ComputeSuccessBallast(rules, races)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][2]int{"S1": {60, 18}, "S2": {0, 0}}, ballastPerPlayer(drivers))
+
+	rules.Decay = 0
+	drivers, err = ComputeSuccessBallast(rules, races)
+	assert.Nil(t, err)
+	assert.Equal(t, [2]int{60, 20}, ballastPerPlayer(drivers)["S1"])
+}
+
+func TestComputeSuccessBallast_InvalidRules(t *testing.T) {
+	_, err := ComputeSuccessBallast(&SuccessBallastRules{Sessions: 0}, nil)
+	assert.NotNil(t, err)
+	_, err = ComputeSuccessBallast(&SuccessBallastRules{Sessions: 1, Decay: 1}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestCfgEntryList_WithBallast(t *testing.T) {
+	entryList := &CfgEntryList{
+		Entries: []*CfgEntryListEntry{
+			{Drivers: []*CfgEntryListDriver{{PlayerID: "S1"}, {PlayerID: "S2"}}, RaceNumber: 12, BallastKg: 5},
+			{Drivers: []*CfgEntryListDriver{{PlayerID: "S9"}}, RaceNumber: 99, BallastKg: 15, Restrictor: 3},
+		},
+	}
+	drivers := []*DriverBallast{
+		{PlayerID: "S2", Car: newTestGridCar(12, "S1", "S2"), BallastKg: 30, Restrictor: 2},
+		{PlayerID: "S1", Car: newTestGridCar(12, "S1", "S2"), BallastKg: 20, Restrictor: 4},
+		{PlayerID: "S3", Car: newTestGridCar(3, "S3"), BallastKg: 10},
+		{PlayerID: "S4", Car: newTestGridCar(4, "S4")},
+	}
+
+	result := entryList.WithBallast(drivers)
+	assert.Len(t, result.Entries, 3)
+	assert.Equal(t, 30, result.Entries[0].BallastKg)
+	assert.Equal(t, 4, result.Entries[0].Restrictor)
+	assert.Equal(t, 0, result.Entries[1].BallastKg)
+	assert.Equal(t, 0, result.Entries[1].Restrictor)
+	assert.Equal(t, 3, result.Entries[2].RaceNumber)
+	assert.Equal(t, 10, result.Entries[2].BallastKg)
+
+	assert.Equal(t, 5, entryList.Entries[0].BallastKg)
+}
@@ -0,0 +1,76 @@
+package accserver
+
+import (
+	"fmt"
+)
+
+// serverVersionCompatibility describes what racce knows about a version of accServer
+type serverVersionCompatibility struct {
+	// configVersion is the configVersion the cfg files must have for this version
+	configVersion int
+	// logMatchersVerified indicates the log matchers are known to match the log of this version
+	logMatchersVerified bool
+}
+
+var (
+	// knownServerVersions contains all accServer versions racce knows about, keyed on the version from the log
+	knownServerVersions = map[int]*serverVersionCompatibility{
+		255: {configVersion: 1, logMatchersVerified: true},
+	}
+)
+
+// checkCfgVersion adds a warning if a cfg file does not have the expected config version
+func checkCfgVersion(warnings []string, file string, configVersion int, expected int) []string {
+	if configVersion != expected {
+		return append(warnings, fmt.Sprintf("%s has configVersion %d, but accServer version expects %d", file,
+			configVersion, expected))
+	}
+	return warnings
+}
+
+// compatibilityWarnings returns warnings about configuration and log parsing known not to match the accServer version
+func compatibilityWarnings(version int, cfg *ServerConfiguration) []string {
+	warnings := make([]string, 0)
+	if version <= 0 {
+		return warnings
+	}
+
+	compatibility, ok := knownServerVersions[version]
+	if !ok {
+		return append(warnings, fmt.Sprintf("accServer version %d is unknown to racce; the live state might be incomplete",
+			version))
+	}
+
+	expected := compatibility.configVersion
+	warnings = checkCfgVersion(warnings, "configuration.json", cfg.Configuration.ConfigVersion, expected)
+	warnings = checkCfgVersion(warnings, "settings.json", cfg.Settings.ConfigVersion, expected)
+	warnings = checkCfgVersion(warnings, "event.json", cfg.Event.ConfigVersion, expected)
+	// The optional files are only checked if they are present
+	if cfg.EventRules != nil {
+		warnings = checkCfgVersion(warnings, "eventRules.json", cfg.EventRules.ConfigVersion, expected)
+	}
+	if cfg.EntryList != nil {
+		warnings = checkCfgVersion(warnings, "entrylist.json", cfg.EntryList.ConfigVersion, expected)
+	}
+	if !compatibility.logMatchersVerified {
+		warnings = append(warnings, fmt.Sprintf("The log of accServer version %d is known not to match racce; the live "+
+			"state might be incomplete", version))
+	}
+	return warnings
+}
+
+// ServerVersion returns the accServer version detected in the log of the most recent run, or 0 if unknown
+func (s *Server) ServerVersion() int {
+	for _, run := range s.Runs() {
+		if run.Version > 0 {
+			return run.Version
+		}
+	}
+	return 0
+}
+
+// CompatibilityWarnings returns warnings about the configuration and log parsing being incompatible with the
+// detected accServer version
+func (s *Server) CompatibilityWarnings() []string {
+	return compatibilityWarnings(s.ServerVersion(), s.Cfg)
+}
@@ -0,0 +1,71 @@
+package accserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCompatibilityCfg(configVersion int) *ServerConfiguration {
+	return &ServerConfiguration{
+		&CfgConfiguration{ConfigVersion: configVersion},
+		&CfgSettings{ConfigVersion: configVersion},
+		&CfgEvent{ConfigVersion: configVersion},
+		&CfgEventRules{ConfigVersion: configVersion},
+		&CfgEntryList{ConfigVersion: configVersion},
+	}
+}
+
+func TestCompatibilityWarnings_Compatible(t *testing.T) {
+	assert.Empty(t, compatibilityWarnings(255, newTestCompatibilityCfg(1)))
+}
+
+func TestCompatibilityWarnings_VersionUnknown(t *testing.T) {
+	assert.Empty(t, compatibilityWarnings(0, newTestCompatibilityCfg(2)))
+}
+
+func TestCompatibilityWarnings_UnknownVersion(t *testing.T) {
+	warnings := compatibilityWarnings(123456, newTestCompatibilityCfg(1))
+	assert.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "123456")
+}
+
+func TestCompatibilityWarnings_ConfigVersionMismatch(t *testing.T) {
+	cfg := newTestCompatibilityCfg(1)
+	cfg.Event.ConfigVersion = 2
+	warnings := compatibilityWarnings(255, cfg)
+	assert.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "event.json")
+}
+
+func TestCompatibilityWarnings_OptionalFiles(t *testing.T) {
+	cfg := newTestCompatibilityCfg(1)
+	cfg.EventRules.ConfigVersion = 2
+	cfg.EntryList.ConfigVersion = 0
+	warnings := compatibilityWarnings(255, cfg)
+	assert.Len(t, warnings, 2)
+	assert.Contains(t, warnings[0], "eventRules.json")
+	assert.Contains(t, warnings[1], "entrylist.json")
+
+	cfg.EventRules = nil
+	cfg.EntryList = nil
+	assert.Empty(t, compatibilityWarnings(255, cfg))
+}
+
+func TestCompatibilityWarnings_LogMatchersNotVerified(t *testing.T) {
+	knownServerVersions[256] = &serverVersionCompatibility{configVersion: 1, logMatchersVerified: false}
+	defer delete(knownServerVersions, 256)
+
+	warnings := compatibilityWarnings(256, newTestCompatibilityCfg(1))
+	assert.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "log")
+}
+
+func TestServer_ServerVersion(t *testing.T) {
+	server := &Server{
+		Cfg:      newTestCompatibilityCfg(1),
+		pastRuns: []RunSummary{{Version: 254}, {Version: 255}, {}},
+	}
+	assert.Equal(t, 255, server.ServerVersion())
+	assert.Empty(t, server.CompatibilityWarnings())
+}
@@ -10,8 +10,21 @@ type Configuration struct {
 	InstallationDir string `json:"installationDir"`
 	ResultsDir      string `json:"resultsDir"`
 	NewResultsDelay int    `json:"newResultsDelay"`
-	ExeWrapper      string `json:"exeWrapper"`
-	LogPrefiltering bool   `json:"logPrefiltering"`
+	// ResultsPollInterval is the number of seconds between scans of the results dir, instead of using file system
+	// events to detect new results files
+	ResultsPollInterval int    `json:"resultsPollInterval"`
+	ExeWrapper          string `json:"exeWrapper"`
+	LogPrefiltering     bool   `json:"logPrefiltering"`
+	// Performance contains the thresholds for warnings about the performance of the accServer process
+	Performance PerformanceThresholds `json:"performance"`
+	// Hotlaps contains the windows of the hotlap leaderboards; hotlaps are not recorded if there are none
+	Hotlaps []*HotlapWindow `json:"hotlaps"`
+	// Rotation specifies the automatic track rotation; the track is never changed automatically if it is nil
+	Rotation *TrackRotationConfiguration `json:"rotation"`
+	// EventTemplates contains user-defined event templates, in addition to the built-in ones
+	EventTemplates []*EventTemplate `json:"eventTemplates"`
+	// SuccessBallast specifies the rules for success ballast; it cannot be applied if it is nil
+	SuccessBallast *SuccessBallastRules `json:"successBallast"`
 }
 
 // installationDir returns the InstallationDir with a single slash at the end
@@ -19,6 +32,16 @@ func (c *Configuration) installationDir() string {
 	return strings.TrimRight(c.InstallationDir, "/") + "/"
 }
 
+// cfgDir returns the directory containing the cfg files of accServer, with a slash at the end
+func (c *Configuration) cfgDir() string {
+	return c.installationDir() + "cfg/"
+}
+
+// savedCfgDir returns the directory where the saved cfg files are kept during a quick session, with a slash at the end
+func (c *Configuration) savedCfgDir() string {
+	return c.cfgDir() + "racce-saved/"
+}
+
 // executable returns the path of accServer.exe
 func (c *Configuration) executable() string {
 	return c.installationDir() + "accServer.exe"
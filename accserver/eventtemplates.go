@@ -0,0 +1,261 @@
+package accserver
+
+import (
+	"fmt"
+)
+
+const (
+	// nightStartHour and nightEndHour mark the hours of the in-game day which are driven in the dark
+	nightStartHour = 20
+	nightEndHour   = 6
+)
+
+// EventTemplate describes an event format which can be applied to any track
+type EventTemplate struct {
+	// Name identifies the template in the admin pages
+	Name string `json:"name"`
+	// Description explains the format of the event
+	Description               string             `json:"description"`
+	PreRaceWaitingTimeSeconds int                `json:"preRaceWaitingTimeSeconds"`
+	SessionOverTimeSeconds    int                `json:"sessionOverTimeSeconds"`
+	PostQualySeconds          int                `json:"postQualySeconds"`
+	PostRaceSeconds           int                `json:"postRaceSeconds"`
+	Sessions                  []*CfgEventSession `json:"sessions"`
+	// Rules replaces the event rules if it is set; the current rules are kept otherwise
+	Rules *CfgEventRules `json:"rules"`
+}
+
+var (
+	// builtinEventTemplates are the templates which are always available
+	builtinEventTemplates = []*EventTemplate{
+		{
+			Name:                      "Sprint",
+			Description:               "Short practice and qualifying, followed by a 25 minute race without mandatory pit stop",
+			PreRaceWaitingTimeSeconds: 80,
+			SessionOverTimeSeconds:    120,
+			PostQualySeconds:          10,
+			PostRaceSeconds:           15,
+			Sessions: []*CfgEventSession{
+				{HourOfDay: 11, DayOfWeekend: 2, TimeMultiplier: 1, SessionType: Practice, SessionDurationMinutes: 20},
+				{HourOfDay: 14, DayOfWeekend: 2, TimeMultiplier: 1, SessionType: Qualifying, SessionDurationMinutes: 10},
+				{HourOfDay: 14, DayOfWeekend: 3, TimeMultiplier: 1, SessionType: Race, SessionDurationMinutes: 25},
+			},
+			Rules: &CfgEventRules{
+				QualifyStandingType:       1,
+				PitWindowLengthSec:        -1,
+				DriverStintTimeSec:        -1,
+				MandatoryPitstopCount:     0,
+				MaxTotalDrivingTime:       -1,
+				MaxDriversCount:           1,
+				IsRefuellingAllowedInRace: true,
+				TyreSetCount:              50,
+			},
+		},
+		{
+			Name:                      "Sprint double-header",
+			Description:               "One qualifying followed by two 25 minute races without mandatory pit stop",
+			PreRaceWaitingTimeSeconds: 80,
+			SessionOverTimeSeconds:    120,
+			PostQualySeconds:          10,
+			PostRaceSeconds:           60,
+			Sessions: []*CfgEventSession{
+				{HourOfDay: 11, DayOfWeekend: 2, TimeMultiplier: 1, SessionType: Practice, SessionDurationMinutes: 20},
+				{HourOfDay: 14, DayOfWeekend: 2, TimeMultiplier: 1, SessionType: Qualifying, SessionDurationMinutes: 15},
+				{HourOfDay: 11, DayOfWeekend: 3, TimeMultiplier: 1, SessionType: Race, SessionDurationMinutes: 25},
+				{HourOfDay: 15, DayOfWeekend: 3, TimeMultiplier: 1, SessionType: Race, SessionDurationMinutes: 25},
+			},
+			Rules: &CfgEventRules{
+				QualifyStandingType:       1,
+				PitWindowLengthSec:        -1,
+				DriverStintTimeSec:        -1,
+				MandatoryPitstopCount:     0,
+				MaxTotalDrivingTime:       -1,
+				MaxDriversCount:           1,
+				IsRefuellingAllowedInRace: true,
+				TyreSetCount:              50,
+			},
+		},
+		{
+			Name:                      "Feature race",
+			Description:               "A 60 minute race with one mandatory pit stop for tyres in a 30 minute pit window",
+			PreRaceWaitingTimeSeconds: 80,
+			SessionOverTimeSeconds:    120,
+			PostQualySeconds:          10,
+			PostRaceSeconds:           15,
+			Sessions: []*CfgEventSession{
+				{HourOfDay: 10, DayOfWeekend: 2, TimeMultiplier: 1, SessionType: Practice, SessionDurationMinutes: 30},
+				{HourOfDay: 14, DayOfWeekend: 2, TimeMultiplier: 1, SessionType: Qualifying, SessionDurationMinutes: 15},
+				{HourOfDay: 14, DayOfWeekend: 3, TimeMultiplier: 2, SessionType: Race, SessionDurationMinutes: 60},
+			},
+			Rules: &CfgEventRules{
+				QualifyStandingType:                  1,
+				PitWindowLengthSec:                   1800,
+				DriverStintTimeSec:                   -1,
+				MandatoryPitstopCount:                1,
+				MaxTotalDrivingTime:                  -1,
+				MaxDriversCount:                      1,
+				IsRefuellingAllowedInRace:            true,
+				IsMandatoryPitstopTyreChangeRequired: true,
+				TyreSetCount:                         50,
+			},
+		},
+		{
+			Name:                      "Endurance",
+			Description:               "A 3 hour team race into the night, with driver swaps and maximum stints of 65 minutes",
+			PreRaceWaitingTimeSeconds: 120,
+			SessionOverTimeSeconds:    180,
+			PostQualySeconds:          10,
+			PostRaceSeconds:           60,
+			Sessions: []*CfgEventSession{
+				{HourOfDay: 12, DayOfWeekend: 1, TimeMultiplier: 1, SessionType: Practice, SessionDurationMinutes: 60},
+				{HourOfDay: 18, DayOfWeekend: 1, TimeMultiplier: 6, SessionType: Practice, SessionDurationMinutes: 30},
+				{HourOfDay: 14, DayOfWeekend: 2, TimeMultiplier: 1, SessionType: Qualifying, SessionDurationMinutes: 20},
+				{HourOfDay: 16, DayOfWeekend: 3, TimeMultiplier: 4, SessionType: Race, SessionDurationMinutes: 180},
+			},
+			Rules: &CfgEventRules{
+				QualifyStandingType:                  1,
+				PitWindowLengthSec:                   -1,
+				DriverStintTimeSec:                   3900,
+				MandatoryPitstopCount:                3,
+				MaxTotalDrivingTime:                  7800,
+				MaxDriversCount:                      3,
+				IsRefuellingAllowedInRace:            true,
+				IsMandatoryPitstopRefuellingRequired: true,
+				IsMandatoryPitstopSwapDriverRequired: true,
+				TyreSetCount:                         50,
+			},
+		},
+	}
+)
+
+// EventTemplates returns the built-in templates followed by the templates from the configuration
+func (s *Server) EventTemplates() []*EventTemplate {
+	return append(append([]*EventTemplate{}, builtinEventTemplates...), s.Config.EventTemplates...)
+}
+
+// EventTemplate returns the template with the given name, or nil if there is none
+func (s *Server) EventTemplate(name string) *EventTemplate {
+	for _, template := range s.EventTemplates() {
+		if template.Name == name {
+			return template
+		}
+	}
+	return nil
+}
+
+// ApplyTo returns a copy of the event on the given track with the sessions and times of the template. All other
+// settings, such as the weather, are taken from the given event.
+func (t *EventTemplate) ApplyTo(event *CfgEvent, track string) *CfgEvent {
+	result := *event
+	result.Track = track
+	result.PreRaceWaitingTimeSeconds = t.PreRaceWaitingTimeSeconds
+	result.SessionOverTimeSeconds = t.SessionOverTimeSeconds
+	result.PostQualySeconds = t.PostQualySeconds
+	result.PostRaceSeconds = t.PostRaceSeconds
+	result.Sessions = make([]*CfgEventSession, len(t.Sessions))
+	for i, session := range t.Sessions {
+		copied := *session
+		result.Sessions[i] = &copied
+	}
+	return &result
+}
+
+// ApplyRulesTo returns the event rules to use with the template, based on the given current rules. The config
+// version is kept from the current rules, if there are any.
+func (t *EventTemplate) ApplyRulesTo(rules *CfgEventRules) *CfgEventRules {
+	if t.Rules == nil {
+		return rules
+	}
+	copied := *t.Rules
+	if rules != nil {
+		copied.ConfigVersion = rules.ConfigVersion
+	} else if copied.ConfigVersion == 0 {
+		copied.ConfigVersion = 1
+	}
+	return &copied
+}
+
+// TimelineSession describes when a single session takes place, both in real time and in-game time
+type TimelineSession struct {
+	SessionType     SessionType
+	DurationMinutes int
+	TimeMultiplier  int
+	// RealStartMinutes is the real time since the start of the event at which the session starts
+	RealStartMinutes int
+	// GameStartMinutes and GameEndMinutes are the in-game times since the start of friday
+	GameStartMinutes int
+	GameEndMinutes   int
+	// Night indicates that part of the session is driven in the dark
+	Night bool
+}
+
+// formatGameTime formats an in-game time since the start of friday as e.g. "Sat 14:00"; long sessions with a high
+// time multiplier can run into the following week
+func formatGameTime(minutes int) string {
+	days := []string{"Fri", "Sat", "Sun", "Mon", "Tue", "Wed", "Thu"}
+	day := minutes / (24 * 60)
+	minuteOfDay := minutes - day*24*60
+	return fmt.Sprintf("%s %02d:%02d", days[day%len(days)], minuteOfDay/60, minuteOfDay%60)
+}
+
+// GameStart returns the in-game start time, e.g. "Sat 14:00"
+func (s *TimelineSession) GameStart() string {
+	return formatGameTime(s.GameStartMinutes)
+}
+
+// GameEnd returns the in-game end time, e.g. "Sat 14:20"
+func (s *TimelineSession) GameEnd() string {
+	return formatGameTime(s.GameEndMinutes)
+}
+
+// RealStart returns the real time since the start of the event, e.g. "1:35"
+func (s *TimelineSession) RealStart() string {
+	return fmt.Sprintf("%d:%02d", s.RealStartMinutes/60, s.RealStartMinutes%60)
+}
+
+// isNight checks if any part of the in-game interval [start, end) lies in the dark
+func isNight(start int, end int) bool {
+	for minute := start; minute < end || minute == start; minute += 30 {
+		hour := (minute / 60) % 24
+		if hour >= nightStartHour || hour < nightEndHour {
+			return true
+		}
+	}
+	return false
+}
+
+// EventTimeline returns when each session of the event takes place. Real times include the waiting times between
+// sessions, but not the over time at the end of a session.
+func EventTimeline(event *CfgEvent) []*TimelineSession {
+	timeline := make([]*TimelineSession, 0, len(event.Sessions))
+	realMinutes := 0
+	for _, session := range event.Sessions {
+		if session.SessionType == Race {
+			realMinutes += event.PreRaceWaitingTimeSeconds / 60
+		}
+		multiplier := session.TimeMultiplier
+		if multiplier <= 0 {
+			multiplier = 1
+		}
+		gameStart := (session.DayOfWeekend-1)*24*60 + session.HourOfDay*60
+		gameEnd := gameStart + session.SessionDurationMinutes*multiplier
+		timeline = append(timeline, &TimelineSession{
+			SessionType:      session.SessionType,
+			DurationMinutes:  session.SessionDurationMinutes,
+			TimeMultiplier:   session.TimeMultiplier,
+			RealStartMinutes: realMinutes,
+			GameStartMinutes: gameStart,
+			GameEndMinutes:   gameEnd,
+			Night:            isNight(gameStart, gameEnd),
+		})
+
+		realMinutes += session.SessionDurationMinutes
+		switch session.SessionType {
+		case Qualifying:
+			realMinutes += event.PostQualySeconds / 60
+		case Race:
+			realMinutes += event.PostRaceSeconds / 60
+		}
+	}
+	return timeline
+}
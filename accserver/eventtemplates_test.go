@@ -0,0 +1,117 @@
+package accserver
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventTemplate_ApplyTo(t *testing.T) {
+	event := newTestEventCfg()
+	event.AmbientTemp = 24
+	event.Rain = 0.3
+	template := builtinEventTemplates[0]
+
+	result := template.ApplyTo(event, "spa")
+	assert.Equal(t, "spa", result.Track)
+	assert.Equal(t, 24, result.AmbientTemp)
+	assert.Equal(t, float32(0.3), result.Rain)
+	assert.Equal(t, template.PreRaceWaitingTimeSeconds, result.PreRaceWaitingTimeSeconds)
+	assert.Equal(t, template.Sessions, result.Sessions)
+	assert.Equal(t, "zandvoort", event.Track)
+
+	result.Sessions[0].SessionDurationMinutes = 1
+	assert.NotEqual(t, 1, template.Sessions[0].SessionDurationMinutes)
+}
+
+func TestEventTemplate_ApplyRulesTo(t *testing.T) {
+	current := &CfgEventRules{MandatoryPitstopCount: 2, ConfigVersion: 2}
+	assert.Equal(t, current, (&EventTemplate{}).ApplyRulesTo(current))
+
+	template := &EventTemplate{Rules: &CfgEventRules{MandatoryPitstopCount: 1}}
+	rules := template.ApplyRulesTo(current)
+	assert.Equal(t, 1, rules.MandatoryPitstopCount)
+	assert.Equal(t, 2, rules.ConfigVersion)
+	rules.MandatoryPitstopCount = 3
+	assert.Equal(t, 1, template.Rules.MandatoryPitstopCount)
+	assert.Equal(t, 0, template.Rules.ConfigVersion)
+
+	assert.Equal(t, 1, template.ApplyRulesTo(nil).ConfigVersion)
+}
+
+func TestServer_EventTemplates(t *testing.T) {
+	custom := &EventTemplate{Name: "League", Sessions: []*CfgEventSession{{SessionType: Race, SessionDurationMinutes: 45}}}
+	server := &Server{Config: &Configuration{EventTemplates: []*EventTemplate{custom}}}
+
+	templates := server.EventTemplates()
+	assert.Len(t, templates, len(builtinEventTemplates)+1)
+	assert.Equal(t, custom, templates[len(templates)-1])
+	assert.Equal(t, custom, server.EventTemplate("League"))
+	assert.Equal(t, builtinEventTemplates[0], server.EventTemplate("Sprint"))
+	assert.Nil(t, server.EventTemplate("Unknown"))
+}
+
+func TestEventTimeline(t *testing.T) {
+	event := &CfgEvent{
+		PreRaceWaitingTimeSeconds: 120,
+		PostQualySeconds:          60,
+		PostRaceSeconds:           60,
+		Sessions: []*CfgEventSession{
+			{HourOfDay: 12, DayOfWeekend: 2, TimeMultiplier: 1, SessionType: Qualifying, SessionDurationMinutes: 20},
+			{HourOfDay: 16, DayOfWeekend: 3, TimeMultiplier: 4, SessionType: Race, SessionDurationMinutes: 180},
+		},
+	}
+
+	timeline := EventTimeline(event)
+	assert.Len(t, timeline, 2)
+
+	assert.Equal(t, 0, timeline[0].RealStartMinutes)
+	assert.Equal(t, "Sat 12:00", timeline[0].GameStart())
+	assert.Equal(t, "Sat 12:20", timeline[0].GameEnd())
+	assert.False(t, timeline[0].Night)
+
+	assert.Equal(t, 23, timeline[1].RealStartMinutes)
+	assert.Equal(t, "0:23", timeline[1].RealStart())
+	assert.Equal(t, "Sun 16:00", timeline[1].GameStart())
+	assert.Equal(t, "Mon 04:00", timeline[1].GameEnd())
+	assert.True(t, timeline[1].Night)
+}
+
+func TestFormatGameTime(t *testing.T) {
+	assert.Equal(t, "Fri 00:00", formatGameTime(0))
+	assert.Equal(t, "Sun 23:59", formatGameTime(3*24*60-1))
+	assert.Equal(t, "Mon 01:30", formatGameTime(3*24*60+90))
+	assert.Equal(t, "Tue 01:30", formatGameTime(4*24*60+90))
+	assert.Equal(t, "Fri 06:00", formatGameTime(7*24*60+6*60))
+}
+
+func TestParseCfg_EventRules(t *testing.T) {
+	server := newTestPreflightServer(t)
+	cfg, err := parseCfg(server.Config.installationDir())
+	assert.Nil(t, err)
+	assert.Nil(t, cfg.EventRules)
+
+	server.Cfg.EventRules = &CfgEventRules{MandatoryPitstopCount: 2, PitWindowLengthSec: -1}
+	assert.Nil(t, server.SaveConfiguration())
+	cfg, err = parseCfg(server.Config.installationDir())
+	assert.Nil(t, err)
+	assert.Equal(t, server.Cfg.EventRules, cfg.EventRules)
+}
+
+func TestParseCfg_MalformedOptionalFiles(t *testing.T) {
+	server := newTestPreflightServer(t)
+	for _, name := range []string{"eventRules.json", "entrylist.json"} {
+		path := filepath.Join(server.Config.installationDir(), "cfg", name)
+		if err := ioutil.WriteFile(path, []byte("{ not json"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := parseCfg(server.Config.installationDir())
+	if assert.Nil(t, err) {
+		assert.Nil(t, cfg.EventRules)
+		assert.Nil(t, cfg.EntryList)
+	}
+}
@@ -0,0 +1,167 @@
+package accserver
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/geniusdex/racce/accresults"
+)
+
+// GridRule describes how the grid is derived from the results of a session
+type GridRule string
+
+const (
+	// GridStraight puts the cars on the grid in finishing order
+	GridStraight GridRule = "straight"
+	// GridReverse puts the cars on the grid in reverse finishing order
+	GridReverse GridRule = "reverse"
+	// GridReverseTopN reverses the order of the first N finishers, followed by all others in finishing order
+	GridReverseTopN GridRule = "reverseTopN"
+	// GridChampionship puts the cars on the grid in order of the championship standings
+	GridChampionship GridRule = "championship"
+)
+
+var (
+	// GridRules contains all rules to generate a grid
+	GridRules = []GridRule{GridStraight, GridReverse, GridReverseTopN, GridChampionship}
+
+	// championshipPoints are the points awarded per finishing position in a race, starting at P1
+	championshipPoints = []int{25, 18, 15, 12, 10, 8, 6, 4, 2, 1}
+)
+
+// ChampionshipPoints returns the points for each player in the given race sessions, keyed on player ID
+func ChampionshipPoints(races []*accresults.Session) map[string]int {
+	points := make(map[string]int)
+	for _, race := range races {
+		if race.SessionType != accresults.Race || race.SessionResult == nil {
+			continue
+		}
+		for position, line := range race.SessionResult.LeaderBoardLines {
+			if position >= len(championshipPoints) {
+				break
+			}
+			for _, driver := range line.Car.Drivers {
+				points[driver.PlayerId] += championshipPoints[position]
+			}
+		}
+	}
+	return points
+}
+
+// carPoints returns the championship points of the best placed driver of the car
+func carPoints(car *accresults.Car, points map[string]int) int {
+	best := 0
+	for _, driver := range car.Drivers {
+		if points[driver.PlayerId] > best {
+			best = points[driver.PlayerId]
+		}
+	}
+	return best
+}
+
+// GenerateGrid returns the cars of the session in the order of the grid for the next race. N is only used for the
+// top N reverse rule, and the championship races are only used for the championship order.
+func GenerateGrid(session *accresults.Session, rule GridRule, n int, championship []*accresults.Session) ([]*accresults.Car, error) {
+	if session.SessionResult == nil {
+		return nil, fmt.Errorf("session has no results")
+	}
+
+	grid := make([]*accresults.Car, 0, len(session.SessionResult.LeaderBoardLines))
+	for _, line := range session.SessionResult.LeaderBoardLines {
+		grid = append(grid, line.Car)
+	}
+
+	switch rule {
+	case GridStraight:
+	case GridReverse:
+		reverseCars(grid)
+	case GridReverseTopN:
+		if n <= 0 {
+			return nil, fmt.Errorf("number of cars to reverse must be positive")
+		}
+		if n > len(grid) {
+			n = len(grid)
+		}
+		reverseCars(grid[:n])
+	case GridChampionship:
+		points := ChampionshipPoints(championship)
+		// Cars with equal points keep their finishing order in the session
+		sort.SliceStable(grid, func(i, j int) bool {
+			return carPoints(grid[i], points) > carPoints(grid[j], points)
+		})
+	default:
+		return nil, fmt.Errorf("unknown grid rule '%s'", rule)
+	}
+	return grid, nil
+}
+
+func reverseCars(cars []*accresults.Car) {
+	for i, j := 0, len(cars)-1; i < j; i, j = i+1, j-1 {
+		cars[i], cars[j] = cars[j], cars[i]
+	}
+}
+
+// findEntry returns the entry containing any of the drivers of the car, or nil if there is none
+func (l *CfgEntryList) findEntry(car *accresults.Car) *CfgEntryListEntry {
+	for _, entry := range l.Entries {
+		for _, entryDriver := range entry.Drivers {
+			for _, driver := range car.Drivers {
+				if entryDriver.PlayerID == driver.PlayerId {
+					return entry
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// newEntryForCar creates an entry for a car from the results
+func newEntryForCar(car *accresults.Car) *CfgEntryListEntry {
+	notForced := -1
+	entry := &CfgEntryListEntry{
+		Drivers:                      make([]*CfgEntryListDriver, 0, len(car.Drivers)),
+		RaceNumber:                   car.RaceNumber,
+		ForcedCarModel:               &notForced,
+		OverrideCarModelForCustomCar: 1,
+		DefaultGridPosition:          -1,
+	}
+	for _, driver := range car.Drivers {
+		entry.Drivers = append(entry.Drivers, &CfgEntryListDriver{
+			FirstName:      driver.FirstName,
+			LastName:       driver.LastName,
+			ShortName:      driver.ShortName,
+			Nationality:    driver.Nationality,
+			DriverCategory: driver.DriverCategory,
+			PlayerID:       driver.PlayerId,
+		})
+	}
+	return entry
+}
+
+// WithGrid returns a copy of the entry list with the default grid positions set to the given grid. Cars which are
+// not in the entry list yet are added; entries which are not on the grid get no default grid position.
+func (l *CfgEntryList) WithGrid(grid []*accresults.Car) *CfgEntryList {
+	result := &CfgEntryList{
+		Entries:  make([]*CfgEntryListEntry, 0, len(grid)),
+		modified: true,
+	}
+	if l != nil {
+		result.ForceEntryList = l.ForceEntryList
+		result.ConfigVersion = l.ConfigVersion
+		for _, entry := range l.Entries {
+			copied := *entry
+			copied.DefaultGridPosition = -1
+			result.Entries = append(result.Entries, &copied)
+		}
+	}
+
+	for position, car := range grid {
+		entry := result.findEntry(car)
+		if entry == nil {
+			entry = newEntryForCar(car)
+			result.Entries = append(result.Entries, entry)
+		}
+		entry.DefaultGridPosition = position + 1
+	}
+	return result
+}
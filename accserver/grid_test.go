@@ -0,0 +1,151 @@
+package accserver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/geniusdex/racce/accresults"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestGridCar(raceNumber int, playerIDs ...string) *accresults.Car {
+	car := &accresults.Car{RaceNumber: raceNumber}
+	for _, playerID := range playerIDs {
+		car.Drivers = append(car.Drivers, &accresults.Driver{FirstName: "Driver", LastName: playerID, ShortName: "DRV", PlayerId: playerID})
+	}
+	return car
+}
+
+func newTestGridSession(sessionType accresults.SessionType, cars ...*accresults.Car) *accresults.Session {
+	session := &accresults.Session{SessionType: sessionType, SessionResult: &accresults.SessionResult{}}
+	for _, car := range cars {
+		session.SessionResult.LeaderBoardLines = append(session.SessionResult.LeaderBoardLines, &accresults.LeaderBoardLine{Car: car})
+	}
+	return session
+}
+
+func raceNumbers(cars []*accresults.Car) []int {
+	numbers := make([]int, 0, len(cars))
+	for _, car := range cars {
+		numbers = append(numbers, car.RaceNumber)
+	}
+	return numbers
+}
+
+func TestGenerateGrid(t *testing.T) {
+	session := newTestGridSession(accresults.Qualifying,
+		newTestGridCar(1, "S1"), newTestGridCar(2, "S2"), newTestGridCar(3, "S3"), newTestGridCar(4, "S4"), newTestGridCar(5, "S5"))
+
+	grid, err := GenerateGrid(session, GridStraight, 0, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, raceNumbers(grid))
+
+	grid, err = GenerateGrid(session, GridReverse, 0, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{5, 4, 3, 2, 1}, raceNumbers(grid))
+
+	grid, err = GenerateGrid(session, GridReverseTopN, 3, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{3, 2, 1, 4, 5}, raceNumbers(grid))
+
+	grid, err = GenerateGrid(session, GridReverseTopN, 8, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{5, 4, 3, 2, 1}, raceNumbers(grid))
+
+	_, err = GenerateGrid(session, GridReverseTopN, 0, nil)
+	assert.NotNil(t, err)
+	_, err = GenerateGrid(session, "random", 0, nil)
+	assert.NotNil(t, err)
+
+	// The session itself is not changed
+	assert.Equal(t, 1, session.SessionResult.LeaderBoardLines[0].Car.RaceNumber)
+}
+
+func TestGenerateGrid_Championship(t *testing.T) {
+	races := []*accresults.Session{
+		newTestGridSession(accresults.Race, newTestGridCar(3, "S3"), newTestGridCar(2, "S2"), newTestGridCar(1, "S1")),
+		newTestGridSession(accresults.Race, newTestGridCar(2, "S2"), newTestGridCar(3, "S3"), newTestGridCar(1, "S1")),
+		newTestGridSession(accresults.Qualifying, newTestGridCar(1, "S1"), newTestGridCar(2, "S2"), newTestGridCar(3, "S3")),
+	}
+	assert.Equal(t, map[string]int{"S3": 43, "S2": 43, "S1": 30}, ChampionshipPoints(races))
+
+	// Car 4 has no points; cars 2 and 3 are equal, so the order of the session is kept
+	session := newTestGridSession(accresults.Qualifying,
+		newTestGridCar(4, "S4"), newTestGridCar(1, "S1"), newTestGridCar(2, "S2"), newTestGridCar(3, "S3", "S5"))
+	grid, err := GenerateGrid(session, GridChampionship, 0, races)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{2, 3, 1, 4}, raceNumbers(grid))
+}
+
+func TestCfgEntryList_RoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "entrylist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "entrylist.json")
+	original := []byte(`{"entries": [{"drivers": [{"playerID": "S7"}], "raceNumber": 7, "customCar": "custom.json"}], "configVersion": 1}`)
+	if err := ioutil.WriteFile(path, original, 0644); err != nil {
+		t.Fatal(err)
+	}
+	entryList := &CfgEntryList{}
+	assert.Nil(t, parseCfgFile(path, entryList))
+	assert.Nil(t, entryList.Entries[0].ForcedCarModel)
+	cfg := &ServerConfiguration{&CfgConfiguration{}, &CfgSettings{}, &CfgEvent{}, nil, entryList}
+
+	// An unchanged entry list is not written
+	assert.Nil(t, writeCfgDir(dir+"/", cfg))
+	contents, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, original, contents)
+
+	// A changed entry list keeps the config version and does not force a car model that was missing
+	cfg.EntryList = entryList.WithGrid([]*accresults.Car{newTestGridCar(7, "S7"), newTestGridCar(1, "S1")})
+	assert.Nil(t, writeCfgDir(dir+"/", cfg))
+	parsed := &CfgEntryList{}
+	assert.Nil(t, parseCfgFile(path, parsed))
+	assert.Equal(t, 1, parsed.ConfigVersion)
+	if assert.Len(t, parsed.Entries, 2) {
+		assert.Nil(t, parsed.Entries[0].ForcedCarModel)
+		assert.Equal(t, "custom.json", parsed.Entries[0].CustomCar)
+		assert.Equal(t, 1, parsed.Entries[0].DefaultGridPosition)
+		assert.Equal(t, -1, *parsed.Entries[1].ForcedCarModel)
+	}
+}
+
+func TestCfgEntryList_WithGrid(t *testing.T) {
+	forcedCarModel := 1
+	entryList := &CfgEntryList{
+		Entries: []*CfgEntryListEntry{
+			{Drivers: []*CfgEntryListDriver{{PlayerID: "S2"}}, RaceNumber: 22, ForcedCarModel: &forcedCarModel,
+				DefaultGridPosition: 1},
+			{Drivers: []*CfgEntryListDriver{{PlayerID: "S9"}}, RaceNumber: 99, DefaultGridPosition: 2},
+		},
+		ForceEntryList: 1,
+		ConfigVersion:  1,
+	}
+	grid := []*accresults.Car{newTestGridCar(1, "S1"), newTestGridCar(2, "S5", "S2")}
+
+	result := entryList.WithGrid(grid)
+	assert.Equal(t, 1, result.ForceEntryList)
+	assert.Equal(t, 1, result.ConfigVersion)
+	assert.Len(t, result.Entries, 3)
+	assert.Equal(t, 22, result.Entries[0].RaceNumber)
+	assert.Equal(t, 1, *result.Entries[0].ForcedCarModel)
+	assert.Equal(t, 2, result.Entries[0].DefaultGridPosition)
+	assert.Equal(t, -1, result.Entries[1].DefaultGridPosition)
+	assert.Equal(t, 1, result.Entries[2].RaceNumber)
+	assert.Equal(t, -1, *result.Entries[2].ForcedCarModel)
+	assert.Equal(t, "S1", result.Entries[2].Drivers[0].PlayerID)
+	assert.Equal(t, 1, result.Entries[2].DefaultGridPosition)
+
+	// The original entry list is not changed
+	assert.Equal(t, 1, entryList.Entries[0].DefaultGridPosition)
+
+	var none *CfgEntryList
+	result = none.WithGrid(grid)
+	assert.Len(t, result.Entries, 2)
+}
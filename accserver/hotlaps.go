@@ -0,0 +1,186 @@
+package accserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// HotlapPeriodDaily is a window containing the current day
+	HotlapPeriodDaily = "daily"
+	// HotlapPeriodWeekly is a window containing the current week, starting on monday
+	HotlapPeriodWeekly = "weekly"
+	// HotlapPeriodCustom is a window with a fixed start and end date
+	HotlapPeriodCustom = "custom"
+
+	// hotlapDateFormat is the format of dates in the hotlap configuration
+	hotlapDateFormat = "2006-01-02"
+)
+
+// HotlapWindow specifies a period of time for which a hotlap leaderboard is shown
+type HotlapWindow struct {
+	// Name is shown on the hotlap page
+	Name string `json:"name"`
+	// Period is one of "daily", "weekly" or "custom"
+	Period string `json:"period"`
+	// Start is the first day of a custom period, formatted as YYYY-MM-DD
+	Start string `json:"start"`
+	// End is the last day of a custom period, formatted as YYYY-MM-DD
+	End string `json:"end"`
+}
+
+// Bounds returns the start (inclusive) and end (exclusive) of the window which contains the given time
+func (w *HotlapWindow) Bounds(now time.Time) (time.Time, time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch w.Period {
+	case HotlapPeriodDaily:
+		return today, today.AddDate(0, 0, 1), nil
+	case HotlapPeriodWeekly:
+		monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		return monday, monday.AddDate(0, 0, 7), nil
+	case HotlapPeriodCustom:
+		start, err := time.ParseInLocation(hotlapDateFormat, w.Start, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid start of hotlap window '%s': %w", w.Name, err)
+		}
+		end, err := time.ParseInLocation(hotlapDateFormat, w.End, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end of hotlap window '%s': %w", w.Name, err)
+		}
+		return start, end.AddDate(0, 0, 1), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("unknown period '%s' for hotlap window '%s'", w.Period, w.Name)
+}
+
+// Hotlap is the best lap of a player on a track in a car model on a single day
+type Hotlap struct {
+	TrackName  string    `json:"trackName"`
+	CarModel   int       `json:"carModel"`
+	PlayerID   string    `json:"playerId"`
+	PlayerName string    `json:"playerName"`
+	LapTimeMS  int       `json:"lapTimeMS"`
+	Time       time.Time `json:"time"`
+}
+
+// day returns the date on which the hotlap was driven
+func (h *Hotlap) day() string {
+	return h.Time.Format(hotlapDateFormat)
+}
+
+// HotlapBoard keeps the best live laps of all players, stored durably on disk
+//
+// Only the best lap per player, track, car model and day is kept. This is enough to build the leaderboard for any
+// window consisting of whole days.
+type HotlapBoard struct {
+	mutex   sync.RWMutex
+	path    string
+	windows []*HotlapWindow
+	laps    []*Hotlap
+}
+
+// LoadHotlapBoard loads the hotlaps stored in the given file, or creates an empty board if the file does not exist
+func LoadHotlapBoard(path string, windows []*HotlapWindow) (*HotlapBoard, error) {
+	board := &HotlapBoard{
+		path:    path,
+		windows: windows,
+		laps:    make([]*Hotlap, 0),
+	}
+
+	for _, window := range windows {
+		if _, _, err := window.Bounds(time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return board, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(contents, &board.laps); err != nil {
+		return nil, fmt.Errorf("cannot parse hotlaps from '%s': %w", path, err)
+	}
+	return board, nil
+}
+
+// Windows returns the configured windows for the leaderboards
+func (b *HotlapBoard) Windows() []*HotlapWindow {
+	return b.windows
+}
+
+// save writes all hotlaps to disk; the caller must hold the lock
+func (b *HotlapBoard) save() error {
+	contents, err := json.Marshal(b.laps)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(b.path, contents, 0644)
+}
+
+// RecordLap adds a valid lap to the board, if it improves the best lap of the player on that day
+func (b *HotlapBoard) RecordLap(lap *Hotlap) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for i, existing := range b.laps {
+		if existing.TrackName == lap.TrackName && existing.CarModel == lap.CarModel &&
+			existing.PlayerID == lap.PlayerID && existing.day() == lap.day() {
+			if lap.LapTimeMS >= existing.LapTimeMS {
+				return
+			}
+			b.laps[i] = lap
+			b.saveOrLog()
+			return
+		}
+	}
+	b.laps = append(b.laps, lap)
+	b.saveOrLog()
+}
+
+func (b *HotlapBoard) saveOrLog() {
+	if err := b.save(); err != nil {
+		log.Printf("Cannot save hotlaps to '%s': %v", b.path, err)
+	}
+}
+
+// Leaderboard returns the best lap per player on a track within the window at the given time, sorted on lap time.
+// A carModel of -1 includes all car models.
+func (b *HotlapBoard) Leaderboard(window *HotlapWindow, now time.Time, trackName string, carModel int) ([]*Hotlap, error) {
+	start, end, err := window.Bounds(now)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	best := make(map[string]*Hotlap)
+	for _, lap := range b.laps {
+		if lap.TrackName != trackName || (carModel >= 0 && lap.CarModel != carModel) ||
+			lap.Time.Before(start) || !lap.Time.Before(end) {
+			continue
+		}
+		if current := best[lap.PlayerID]; current == nil || lap.LapTimeMS < current.LapTimeMS {
+			best[lap.PlayerID] = lap
+		}
+	}
+
+	leaderboard := make([]*Hotlap, 0, len(best))
+	for _, lap := range best {
+		leaderboard = append(leaderboard, lap)
+	}
+	sort.Slice(leaderboard, func(i, j int) bool {
+		if leaderboard[i].LapTimeMS != leaderboard[j].LapTimeMS {
+			return leaderboard[i].LapTimeMS < leaderboard[j].LapTimeMS
+		}
+		return leaderboard[i].Time.Before(leaderboard[j].Time)
+	})
+	return leaderboard, nil
+}
@@ -0,0 +1,130 @@
+package accserver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestHotlapBoard(t *testing.T, windows ...*HotlapWindow) (*HotlapBoard, string) {
+	dir, err := ioutil.TempDir("", "hotlaps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "hotlaps.json")
+	board, err := LoadHotlapBoard(path, windows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return board, path
+}
+
+func TestHotlapWindow_Bounds(t *testing.T) {
+	// Wednesday
+	now := time.Date(2020, 6, 3, 20, 15, 0, 0, time.UTC)
+
+	start, end, err := (&HotlapWindow{Period: HotlapPeriodDaily}).Bounds(now)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2020, 6, 3, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2020, 6, 4, 0, 0, 0, 0, time.UTC), end)
+
+	start, end, err = (&HotlapWindow{Period: HotlapPeriodWeekly}).Bounds(now)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2020, 6, 8, 0, 0, 0, 0, time.UTC), end)
+
+	start, end, err = (&HotlapWindow{Period: HotlapPeriodWeekly}).Bounds(time.Date(2020, 6, 7, 23, 0, 0, 0, time.UTC))
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2020, 6, 8, 0, 0, 0, 0, time.UTC), end)
+
+	start, end, err = (&HotlapWindow{Period: HotlapPeriodCustom, Start: "2020-05-01", End: "2020-06-30"}).Bounds(now)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC), end)
+
+	_, _, err = (&HotlapWindow{Period: HotlapPeriodCustom, Start: "May 1st"}).Bounds(now)
+	assert.NotNil(t, err)
+	_, _, err = (&HotlapWindow{Period: "monthly"}).Bounds(now)
+	assert.NotNil(t, err)
+}
+
+func TestHotlapBoard_Leaderboard(t *testing.T) {
+	daily := &HotlapWindow{Name: "Today", Period: HotlapPeriodDaily}
+	weekly := &HotlapWindow{Name: "This week", Period: HotlapPeriodWeekly}
+	board, path := newTestHotlapBoard(t, daily, weekly)
+
+	monday := time.Date(2020, 6, 1, 20, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
+	board.RecordLap(&Hotlap{"zandvoort", 5, "S1", "Driver One", 95000, monday})
+	board.RecordLap(&Hotlap{"zandvoort", 5, "S1", "Driver One", 96000, tuesday})
+	board.RecordLap(&Hotlap{"zandvoort", 5, "S1", "Driver One", 95500, tuesday.Add(time.Hour)})
+	board.RecordLap(&Hotlap{"zandvoort", 6, "S2", "Driver Two", 95200, tuesday})
+	board.RecordLap(&Hotlap{"monza", 5, "S2", "Driver Two", 105000, tuesday})
+
+	laps, err := board.Leaderboard(daily, tuesday, "zandvoort", -1)
+	assert.Nil(t, err)
+	if assert.Len(t, laps, 2) {
+		assert.Equal(t, "S2", laps[0].PlayerID)
+		assert.Equal(t, 95200, laps[0].LapTimeMS)
+		assert.Equal(t, "S1", laps[1].PlayerID)
+		assert.Equal(t, 95500, laps[1].LapTimeMS)
+	}
+
+	laps, err = board.Leaderboard(weekly, tuesday, "zandvoort", -1)
+	assert.Nil(t, err)
+	if assert.Len(t, laps, 2) {
+		assert.Equal(t, "S1", laps[0].PlayerID)
+		assert.Equal(t, 95000, laps[0].LapTimeMS)
+	}
+
+	laps, err = board.Leaderboard(weekly, tuesday, "zandvoort", 6)
+	assert.Nil(t, err)
+	if assert.Len(t, laps, 1) {
+		assert.Equal(t, "S2", laps[0].PlayerID)
+	}
+
+	laps, err = board.Leaderboard(weekly, tuesday.AddDate(0, 0, 7), "zandvoort", -1)
+	assert.Nil(t, err)
+	assert.Empty(t, laps)
+
+	// All laps are stored durably
+	reloaded, err := LoadHotlapBoard(path, board.Windows())
+	assert.Nil(t, err)
+	laps, err = reloaded.Leaderboard(weekly, tuesday, "zandvoort", -1)
+	assert.Nil(t, err)
+	assert.Len(t, laps, 2)
+}
+
+func TestLiveState_RecordHotlaps(t *testing.T) {
+	f := newTestLiveStateFixture(t)
+	daily := &HotlapWindow{Name: "Today", Period: HotlapPeriodDaily}
+	f.state.hotlaps, _ = newTestHotlapBoard(t, daily)
+
+	f.logEvents <- logEventTrack{"zandvoort"}
+	<-f.events.Track
+
+	f.logEvents <- logEventNewConnectionRequest{6, "Driver One", "S76543210987654321", 5}
+	f.logEvents <- logEventNewCarConnection{1002, 5, 42}
+	<-f.events.CarState
+
+	f.logEvents <- logEventNewLapTime{1002, 0, 130000, 100, flagLapIsOutLap}
+	<-f.events.CarState
+	f.logEvents <- logEventNewLapTime{1002, 0, 95000, 200, flagLapHasCut}
+	<-f.events.CarState
+	f.logEvents <- logEventNewLapTime{1002, 0, 97000, 300, 0}
+	assert.NotNil(t, <-f.events.Lap)
+	<-f.events.CarState
+
+	laps, err := f.state.hotlaps.Leaderboard(daily, f.now, "zandvoort", -1)
+	assert.Nil(t, err)
+	if assert.Len(t, laps, 1) {
+		assert.Equal(t, &Hotlap{"zandvoort", 5, "S76543210987654321", "Driver One", 97000, f.now}, laps[0])
+	}
+}
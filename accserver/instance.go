@@ -6,6 +6,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 // InstanceState is the state of a possible running instance
@@ -17,11 +20,73 @@ const (
 	Running
 )
 
+// RunSummary describes a single run of an accServer instance
+type RunSummary struct {
+	// StartTime is the time at which the process was started
+	StartTime time.Time
+	// EndTime is the time at which the process exited, or zero if it is still running
+	EndTime time.Time
+	// ExitCode is the exit code of the process, or -1 if it was terminated by a signal or is still running
+	ExitCode int
+	// Signal is the name of the signal which terminated the process, or empty if none
+	Signal string
+	// Killed indicates the process was stopped from racce
+	Killed bool
+	// Version is the accServer version detected in the log, or 0 if unknown
+	Version int
+}
+
+// HasEnded checks if the process of the run has exited
+func (r RunSummary) HasEnded() bool {
+	return !r.EndTime.IsZero()
+}
+
+// Runtime returns the time the process ran, or has been running until now
+func (r RunSummary) Runtime() time.Duration {
+	if r.HasEnded() {
+		return r.EndTime.Sub(r.StartTime)
+	}
+	return time.Since(r.StartTime)
+}
+
+// formatRuntime formats a duration with at most two units, like "3h12m" or "4m05s"
+func formatRuntime(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d >= time.Hour {
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	if d >= time.Minute {
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%ds", int(d.Seconds()))
+}
+
+// String describes the run in a single line, e.g. "ran 3h12m, exited with code 1"
+func (r RunSummary) String() string {
+	runtime := formatRuntime(r.Runtime())
+	switch {
+	case !r.HasEnded():
+		return "running for " + runtime
+	case r.Killed:
+		return "ran " + runtime + ", stopped from racce"
+	case r.Signal != "":
+		return "ran " + runtime + ", terminated by signal " + r.Signal
+	default:
+		return fmt.Sprintf("ran %s, exited with code %d", runtime, r.ExitCode)
+	}
+}
+
 // Instance represents a running instance of an accServer
 type Instance struct {
-	cmd       *exec.Cmd
-	hasKilled bool
-	log       *serverLog
+	cmd         *exec.Cmd
+	hasKilled   bool
+	log         *serverLog
+	performance *PerformanceMonitor
+	// exited is closed when the process has exited
+	exited chan bool
+	// summaryMutex protects summary, which is updated when the process exits
+	summaryMutex sync.Mutex
+	summary      RunSummary
 }
 
 func makeCmd(accServer string, exeWrapper string) *exec.Cmd {
@@ -40,15 +105,19 @@ func cmdString(cmd *exec.Cmd) string {
 
 func newInstance(config *Configuration) (*Instance, error) {
 	cmd := makeCmd(config.executable(), config.exeWrapper())
-	serverLog, err := newServerLog(cmd, config.LogPrefiltering)
+	performance := newPerformanceMonitor(config.Performance)
+	serverLog, err := newServerLog(cmd, config.LogPrefiltering, performance.recordLate)
 	if err != nil {
 		return nil, err
 	}
 
 	i := &Instance{
-		cmd:       cmd,
-		hasKilled: false,
-		log:       serverLog,
+		cmd:         cmd,
+		hasKilled:   false,
+		log:         serverLog,
+		performance: performance,
+		exited:      make(chan bool),
+		summary:     RunSummary{ExitCode: -1},
 	}
 
 	log.Printf("Starting %s...", cmdString(i.cmd))
@@ -56,9 +125,12 @@ func newInstance(config *Configuration) (*Instance, error) {
 	if err := i.cmd.Start(); err != nil {
 		return nil, err
 	}
+	i.summary.StartTime = time.Now()
 
 	go i.wait()
 
+	go i.performance.run(i.cmd.Process.Pid, i.exited)
+
 	go i.printLog(i.NewLogChannel())
 
 	return i, nil
@@ -76,6 +148,28 @@ func (i *Instance) State() InstanceState {
 	return Running
 }
 
+// Performance returns the performance monitor of the instance. A nil instance is accepted and resolves to nil.
+func (i *Instance) Performance() *PerformanceMonitor {
+	if i == nil {
+		return nil
+	}
+	return i.performance
+}
+
+// setVersion stores the accServer version detected in the log
+func (i *Instance) setVersion(version int) {
+	i.summaryMutex.Lock()
+	defer i.summaryMutex.Unlock()
+	i.summary.Version = version
+}
+
+// Summary returns a summary of the run of this instance
+func (i *Instance) Summary() RunSummary {
+	i.summaryMutex.Lock()
+	defer i.summaryMutex.Unlock()
+	return i.summary
+}
+
 // IsRunning returns if the instance is running (State() == Running)
 func (i *Instance) IsRunning() bool {
 	return i.State() == Running
@@ -109,6 +203,20 @@ func (i *Instance) wait() {
 	} else {
 		log.Printf("The accServer process has exited normally")
 	}
+
+	i.summaryMutex.Lock()
+	i.summary.EndTime = time.Now()
+	i.summary.Killed = i.hasKilled
+	if state := i.cmd.ProcessState; state != nil {
+		i.summary.ExitCode = state.ExitCode()
+		if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			i.summary.Signal = status.Signal().String()
+		}
+	}
+	log.Printf("The accServer process %s", i.summary)
+	i.summaryMutex.Unlock()
+
+	close(i.exited)
 }
 
 // NewLogChannel creates a new channel over which all log will be sent, starting from the beginning of the server start
@@ -124,6 +232,10 @@ func (i *Instance) NewLogChannel() <-chan LogMessage {
 // printLog prints the server log to standard output
 func (i *Instance) printLog(logChannel <-chan LogMessage) {
 	for msg := range logChannel {
-		log.Printf(" >>>  %s", msg.Message)
+		if msg.Stream == LogStreamStderr {
+			log.Printf(" !!!  %s", msg.Message)
+		} else {
+			log.Printf(" >>>  %s", msg.Message)
+		}
 	}
 }
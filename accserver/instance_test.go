@@ -0,0 +1,75 @@
+package accserver
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestInstance starts a shell script as accServer
+func newTestInstance(t *testing.T, script string) *Instance {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no shell available to run a fake accServer")
+	}
+
+	dir, err := ioutil.TempDir("", "accserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err := ioutil.WriteFile(filepath.Join(dir, "accServer.exe"), []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	instance, err := newInstance(&Configuration{InstallationDir: dir, ExeWrapper: sh})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return instance
+}
+
+func TestInstance_LogStreamsAndExitCode(t *testing.T) {
+	instance := newTestInstance(t, "echo 'Server starting with version 255'\necho 'wine: could not load kernel32.dll, status c0000135' >&2\nexit 3\n")
+
+	messages := make(map[LogStream][]string)
+	for msg := range instance.NewLogChannel() {
+		messages[msg.Stream] = append(messages[msg.Stream], msg.Message)
+	}
+	assert.Equal(t, []string{"Server starting with version 255"}, messages[LogStreamStdout])
+	assert.Equal(t, []string{"wine: could not load kernel32.dll, status c0000135"}, messages[LogStreamStderr])
+
+	<-instance.exited
+	summary := instance.Summary()
+	assert.True(t, summary.HasEnded())
+	assert.Equal(t, 3, summary.ExitCode)
+	assert.Equal(t, "", summary.Signal)
+	assert.False(t, summary.Killed)
+	assert.Regexp(t, `^ran \d+s, exited with code 3$`, summary.String())
+}
+
+func TestInstance_Killed(t *testing.T) {
+	instance := newTestInstance(t, "echo started\nexec sleep 10\n")
+	<-instance.NewLogChannel()
+
+	assert.Nil(t, instance.stop())
+	<-instance.exited
+	summary := instance.Summary()
+	assert.Equal(t, -1, summary.ExitCode)
+	assert.Equal(t, "killed", summary.Signal)
+	assert.True(t, summary.Killed)
+	assert.Regexp(t, `^ran \d+s, stopped from racce$`, summary.String())
+}
+
+func TestRunSummary_String(t *testing.T) {
+	start := time.Date(2020, 6, 1, 20, 0, 0, 0, time.UTC)
+	assert.Equal(t, "ran 3h12m, exited with code 1", RunSummary{start, start.Add(192 * time.Minute), 1, "", false, 0}.String())
+	assert.Equal(t, "ran 4m05s, terminated by signal segmentation fault", RunSummary{start, start.Add(245 * time.Second), -1, "segmentation fault", false, 0}.String())
+	assert.Equal(t, "ran 12s, exited with code 0", RunSummary{start, start.Add(12 * time.Second), 0, "", false, 0}.String())
+	assert.Regexp(t, `^running for \d+h\d\dm$`, RunSummary{start, time.Time{}, -1, "", false, 0}.String())
+}
@@ -0,0 +1,110 @@
+package accserver
+
+import (
+	"strconv"
+)
+
+// LapRecord describes a kind of best lap time that can be improved by a lap
+type LapRecord string
+
+const (
+	// LapRecordPersonalBest is the fastest lap of the car in the current session
+	LapRecordPersonalBest LapRecord = "personal_best"
+	// LapRecordSessionBest is the fastest lap of all cars in the current session
+	LapRecordSessionBest LapRecord = "session_best"
+	// LapRecordPlayerTrackBest is the fastest lap ever of the driver on the track
+	LapRecordPlayerTrackBest LapRecord = "player_track_best"
+	// LapRecordPlayerTrackCarBest is the fastest lap ever of the driver on the track in the car model
+	LapRecordPlayerTrackCarBest LapRecord = "player_track_car_best"
+	// LapRecordTrackCarRecord is the fastest lap ever on the track in the car model
+	LapRecordTrackCarRecord LapRecord = "track_car_record"
+	// LapRecordTrackRecord is the fastest lap ever on the track
+	LapRecordTrackRecord LapRecord = "track_record"
+)
+
+// LapNotification announces a completed lap which improved one or more best lap times
+type LapNotification struct {
+	// CarID is the car which completed the lap
+	CarID int
+	// Driver is the driver who completed the lap; can be nil if unknown
+	Driver *Driver
+	// LapTimeMS is the lap time
+	LapTimeMS int
+	// Records contains all best lap times improved by this lap
+	Records []LapRecord
+}
+
+// HasRecord checks if a specific best lap time was improved by this lap
+func (n *LapNotification) HasRecord(record LapRecord) bool {
+	for _, r := range n.Records {
+		if r == record {
+			return true
+		}
+	}
+	return false
+}
+
+// LapHistory provides the best lap times from the past to compare live laps against
+type LapHistory interface {
+	// BestLap returns the fastest lap on a track, optionally limited to a single car model and/or player. A carModel
+	// of -1 and an empty playerId include all car models and players. It returns 0 if no lap is known.
+	BestLap(trackName string, carModel int, playerId string) int
+}
+
+// lapRecordKeeper tracks the best lap times for all kinds of records during the lifetime of the live state
+type lapRecordKeeper struct {
+	// history contains the historical lap times, or nil if not available
+	history LapHistory
+	// liveBests contains the best lap times set since startup, keyed on record scope
+	liveBests map[string]int
+}
+
+func newLapRecordKeeper() *lapRecordKeeper {
+	return &lapRecordKeeper{
+		history:   nil,
+		liveBests: make(map[string]int),
+	}
+}
+
+// liveBestKey builds a key into liveBests for a track, car model (-1 for all) and player (empty for all)
+func liveBestKey(trackName string, carModel int, playerID string) string {
+	return trackName + "/" + strconv.Itoa(carModel) + "/" + playerID
+}
+
+// improves checks if a lap time beats the best known time for a scope, and records it as best if it does
+//
+// When there is no best time known at all, the lap is not considered to be a record.
+func (k *lapRecordKeeper) improves(lapTimeMS int, trackName string, carModel int, playerID string) bool {
+	key := liveBestKey(trackName, carModel, playerID)
+	best := k.liveBests[key]
+	if k.history != nil {
+		if historicBest := k.history.BestLap(trackName, carModel, playerID); historicBest > 0 && (best <= 0 || historicBest < best) {
+			best = historicBest
+		}
+	}
+
+	if best <= 0 || lapTimeMS < best {
+		k.liveBests[key] = lapTimeMS
+	}
+	return best > 0 && lapTimeMS < best
+}
+
+// historicRecords determines which historic records are improved by a lap on a track in a car model by a player
+func (k *lapRecordKeeper) historicRecords(lapTimeMS int, trackName string, carModel int, driver *Driver) []LapRecord {
+	records := make([]LapRecord, 0)
+	if driver != nil {
+		if k.improves(lapTimeMS, trackName, -1, driver.PlayerID) {
+			records = append(records, LapRecordPlayerTrackBest)
+		}
+		if carModel >= 0 && k.improves(lapTimeMS, trackName, carModel, driver.PlayerID) {
+			records = append(records, LapRecordPlayerTrackCarBest)
+		}
+	}
+	if carModel >= 0 && k.improves(lapTimeMS, trackName, carModel, "") {
+		records = append(records, LapRecordTrackCarRecord)
+	}
+	if k.improves(lapTimeMS, trackName, -1, "") {
+		records = append(records, LapRecordTrackRecord)
+	}
+	return records
+}
@@ -2,9 +2,12 @@ package accserver
 
 import (
 	"log"
+	"math"
 	"sort"
+	"time"
 
 	"github.com/geniusdex/racce/accdata"
+	"github.com/geniusdex/racce/accresults"
 )
 
 // ServerState represents the current state of the server instance
@@ -28,6 +31,20 @@ const (
 	SessionPhaseSession           string = "session"
 	SessionPhaseSessionOvertime   string = "session overtime"
 	SessionPhaseSessionCompleted  string = "session completed"
+
+	// nrLapsForAverage is the number of recent laps used to calculate the average lap time of a car
+	nrLapsForAverage = 3
+	// connectionRequestTimeout is the time after which a connection request without car connection is discarded
+	connectionRequestTimeout = 30 * time.Second
+)
+
+var (
+	// liveSessionTypes maps the session types from the event configuration to the types used in the log
+	liveSessionTypes = map[SessionType]string{
+		Practice:   SessionTypePractice,
+		Qualifying: SessionTypeQualifying,
+		Race:       SessionTypeRace,
+	}
 )
 
 // Driver contains the information about a single driver
@@ -37,6 +54,25 @@ type Driver struct {
 	PlayerID     string
 }
 
+// connectionRequest is a connection request which has not yet been associated with a car
+type connectionRequest struct {
+	logEventNewConnectionRequest
+	// time is when the request was received
+	time time.Time
+	// ambiguous is set when a car connection of the same car model was matched to another request while this one was
+	// pending, so either match may be wrong
+	ambiguous bool
+}
+
+// ambiguousMatch is a car connection which was matched to one of several pending connection requests for the same
+// car model; it can be corrected when a lap shows who is really driving the car
+type ambiguousMatch struct {
+	carID  int
+	driver *Driver
+	// newSlot is set when the driver got a new driver slot in the car because of this match
+	newSlot bool
+}
+
 // CarState represents the current state of a single car
 type CarState struct {
 	CarID              int
@@ -49,6 +85,21 @@ type CarState struct {
 	BestLapMS          int
 	LastLapMS          int
 	LastLapTimestampMS int
+	// LastLapTime is the wall clock time at which the last lap was completed
+	LastLapTime time.Time
+	// AverageLapMS is the average lap time over the most recent laps, excluding in and out laps
+	AverageLapMS int
+	// EstimatedRemainingLaps is the number of laps the car still has to start or complete before the session ends,
+	// estimated when it completed its last lap; it is 0 if no estimate can be made
+	EstimatedRemainingLaps int
+
+	// recentLapsMS contains the most recent lap times used to calculate AverageLapMS
+	recentLapsMS []int
+	// sessionDrivers contains all drivers which have been in the car during the current session
+	sessionDrivers []*Driver
+	// driverSlots contains every player who joined the car, in the order they joined, matching the driver IDs used by
+	// accServer. Unlike Drivers it is never compacted; a player who reconnects keeps the same slot.
+	driverSlots []*Driver
 }
 
 func newCarState() *CarState {
@@ -61,6 +112,62 @@ func newCarState() *CarState {
 type SessionState struct {
 	Type  string
 	Phase string
+	// Index is the index of the matching session in the event configuration, or -1 if unknown
+	Index int
+	// DurationMinutes is the configured duration of the session, or 0 if unknown
+	DurationMinutes int
+	// StartTime is the time at which the session clock started running; zero if not started yet
+	StartTime time.Time
+	// Clock is the session clock at the time the state was sent to the listeners
+	Clock *SessionClock
+}
+
+// SessionClock is a snapshot of the session clock, taken on the server so clients do not depend on their own clock
+type SessionClock struct {
+	// Started indicates if the session clock has started running
+	Started bool
+	// ElapsedMS is the time elapsed since the session clock started
+	ElapsedMS int64
+	// RemainingMS is the time remaining in the session, or -1 if the duration of the session is unknown
+	RemainingMS int64
+}
+
+// HasStarted indicates if the session clock has started running
+func (s *SessionState) HasStarted() bool {
+	return !s.StartTime.IsZero()
+}
+
+// Elapsed returns the time elapsed since the session clock started
+func (s *SessionState) Elapsed(now time.Time) time.Duration {
+	if !s.HasStarted() {
+		return 0
+	}
+	return now.Sub(s.StartTime)
+}
+
+// Remaining returns the time remaining in the session, which is 0 if the session is over or its duration is unknown
+func (s *SessionState) Remaining(now time.Time) time.Duration {
+	if !s.HasStarted() || s.DurationMinutes <= 0 {
+		return 0
+	}
+	remaining := time.Duration(s.DurationMinutes)*time.Minute - s.Elapsed(now)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// clockAt returns a snapshot of the session clock at the given time
+func (s *SessionState) clockAt(now time.Time) *SessionClock {
+	clock := &SessionClock{
+		Started:     s.HasStarted(),
+		ElapsedMS:   s.Elapsed(now).Milliseconds(),
+		RemainingMS: s.Remaining(now).Milliseconds(),
+	}
+	if s.DurationMinutes <= 0 {
+		clock.RemainingMS = -1
+	}
+	return clock
 }
 
 // LiveStateEvents contains channels for all types of events sent
@@ -76,6 +183,7 @@ type LiveStateEvents struct {
 	SessionState chan *SessionState
 	CarState     chan *CarState
 	CarPurged    chan int
+	Lap          chan *LapNotification
 }
 
 // Flush reads all remaining events on all channels until they are closed.
@@ -94,6 +202,8 @@ func (events *LiveStateEvents) Flush() {
 	}
 	for range events.CarPurged {
 	}
+	for range events.Lap {
+	}
 }
 
 // LiveState is the live state of the accServer
@@ -113,12 +223,34 @@ type LiveState struct {
 	eventListeners []*LiveStateEvents
 	// stopMonitoring is a channel used to indicate when the monitoring should stop
 	stopMonitoring chan bool
-	// connectionRequests contains the yet unhandled connection requests
-	connectionRequests []*logEventNewConnectionRequest
+	// connectionRequests contains the yet unhandled connection requests, in the order they were received
+	connectionRequests []*connectionRequest
 	// driverPerConnection contains the driver associated with each connection
 	driverPerConnection map[int]*Driver
 	// carPerConnection maps connection IDs to the car ID they they are online for
 	carPerConnection map[int]int
+	// ambiguousMatches contains the car connections which could have belonged to another connection request
+	ambiguousMatches []*ambiguousMatch
+	// eventCfg is the event configuration of the running instance, or nil if unknown
+	eventCfg *CfgEvent
+	// now returns the current time; it can be replaced for testing
+	now func() time.Time
+	// lapRecords keeps track of best lap times to detect new records
+	lapRecords *lapRecordKeeper
+	// sessionLaps contains all laps completed in the current session
+	sessionLaps []*recordedLap
+	// sessionRecordingStart is the time at which recording the laps of the current session started
+	sessionRecordingStart time.Time
+	// hotlaps records all valid laps for the hotlap leaderboards, or nil if not enabled
+	hotlaps *HotlapBoard
+	// weekendReset is called whenever accServer resets the race weekend
+	weekendReset func()
+	// serverEmptied is called whenever the last client leaves the server
+	serverEmptied func()
+	// serverVersionDetected is called with the accServer version when it is found in the log
+	serverVersionDetected func(version int)
+	// sessionEnded is called with the reconstructed results and recording start time whenever a session with laps ends
+	sessionEnded func(session *accresults.Session, since time.Time)
 }
 
 func newLiveState() *LiveState {
@@ -126,11 +258,14 @@ func newLiveState() *LiveState {
 		ServerState:         ServerStateOffline,
 		NrClients:           0,
 		Track:               accdata.Tracks[0],
-		SessionState:        &SessionState{SessionTypePractice, SessionPhaseWaitingForDrivers},
+		SessionState:        &SessionState{Type: SessionTypePractice, Phase: SessionPhaseWaitingForDrivers, Index: -1},
 		CarState:            make(map[int]*CarState),
-		connectionRequests:  make([]*logEventNewConnectionRequest, 0),
+		connectionRequests:  make([]*connectionRequest, 0),
 		driverPerConnection: make(map[int]*Driver),
 		carPerConnection:    make(map[int]int),
+		now:                 time.Now,
+		lapRecords:          newLapRecordKeeper(),
+		sessionLaps:         make([]*recordedLap, 0),
 	}
 }
 
@@ -143,6 +278,7 @@ func (ls *LiveState) NewEventChannels() *LiveStateEvents {
 		SessionState: make(chan *SessionState),
 		CarState:     make(chan *CarState),
 		CarPurged:    make(chan int),
+		Lap:          make(chan *LapNotification),
 	}
 
 	ls.eventListeners = append(ls.eventListeners, events)
@@ -157,6 +293,34 @@ func (ls *LiveState) IsRunning() bool {
 	return ls.ServerState == ServerStateOnline || ls.ServerState == ServerStateNotRegistered
 }
 
+// CurrentSessionState returns a copy of the state of the current session, with the session clock as of now
+func (ls *LiveState) CurrentSessionState() *SessionState {
+	state := *ls.SessionState
+	state.Clock = state.clockAt(ls.now())
+	return &state
+}
+
+// estimateRemainingLaps estimates the number of laps a car still has to start or complete before the session ends,
+// based on the average of its recent lap times. It returns 0 if no estimate can be made.
+func (ls *LiveState) estimateRemainingLaps(car *CarState) int {
+	if car.AverageLapMS <= 0 || ls.SessionState.DurationMinutes <= 0 || !ls.SessionState.HasStarted() {
+		return 0
+	}
+
+	now := ls.now()
+	lapStart := car.LastLapTime
+	if lapStart.IsZero() {
+		lapStart = ls.SessionState.StartTime
+	}
+	average := time.Duration(car.AverageLapMS) * time.Millisecond
+	inLap := now.Sub(lapStart)
+	if inLap >= average {
+		inLap = average - time.Millisecond
+	}
+
+	return int(math.Ceil(float64(ls.SessionState.Remaining(now)+inLap) / float64(average)))
+}
+
 //--- State updates ---//
 
 func (ls *LiveState) setServerState(value ServerState) {
@@ -181,6 +345,7 @@ func (ls *LiveState) setTrack(track *accdata.Track) {
 }
 
 func (ls *LiveState) setSessionState(state *SessionState) {
+	state.Clock = state.clockAt(ls.now())
 	ls.SessionState = state
 	for _, listener := range ls.eventListeners {
 		listener.SessionState <- state
@@ -196,11 +361,20 @@ func (ls *LiveState) setCarState(carState *CarState) {
 
 func (ls *LiveState) purgeCar(carID int) {
 	delete(ls.CarState, carID)
+	ls.removeAmbiguousMatches(func(match *ambiguousMatch) bool {
+		return match.carID == carID
+	})
 	for _, listener := range ls.eventListeners {
 		listener.CarPurged <- carID
 	}
 }
 
+func (ls *LiveState) notifyLap(notification *LapNotification) {
+	for _, listener := range ls.eventListeners {
+		listener.Lap <- notification
+	}
+}
+
 //--- Helper functions ---//
 
 func (ls *LiveState) serverOffline() {
@@ -208,25 +382,308 @@ func (ls *LiveState) serverOffline() {
 	for _, car := range ls.CarState {
 		ls.purgeCar(car.CarID)
 	}
+	ls.connectionRequests = make([]*connectionRequest, 0)
+	ls.driverPerConnection = make(map[int]*Driver)
+	ls.carPerConnection = make(map[int]int)
 }
 
-func (ls *LiveState) lookupDriverForNewCarConnection(carEvent logEventNewCarConnection) *Driver {
-	for i := len(ls.connectionRequests) - 1; i >= 0; i-- {
-		connEvent := ls.connectionRequests[i]
-		if connEvent.CarModelID == carEvent.CarModelID {
-			lastIndex := len(ls.connectionRequests) - 1
-			ls.connectionRequests[i] = ls.connectionRequests[lastIndex]
-			ls.connectionRequests = ls.connectionRequests[:lastIndex]
-			driver := &Driver{
-				ConnectionID: connEvent.ConnectionID,
-				Name:         connEvent.PlayerName,
-				PlayerID:     connEvent.SteamID,
+// removeConnectionRequests removes all pending connection requests for which the filter returns true
+func (ls *LiveState) removeConnectionRequests(filter func(request *connectionRequest) bool) {
+	remaining := ls.connectionRequests[:0]
+	for _, request := range ls.connectionRequests {
+		if !filter(request) {
+			remaining = append(remaining, request)
+		}
+	}
+	ls.connectionRequests = remaining
+}
+
+// removeStaleConnectionRequests removes connection requests which did not result in a car connection in time, e.g.
+// because the connection was rejected by the server
+func (ls *LiveState) removeStaleConnectionRequests() {
+	deadline := ls.now().Add(-connectionRequestTimeout)
+	ls.removeConnectionRequests(func(request *connectionRequest) bool {
+		return request.time.Before(deadline)
+	})
+}
+
+// removeAmbiguousMatches removes all ambiguous matches for which the filter returns true
+func (ls *LiveState) removeAmbiguousMatches(filter func(match *ambiguousMatch) bool) {
+	remaining := ls.ambiguousMatches[:0]
+	for _, match := range ls.ambiguousMatches {
+		if !filter(match) {
+			remaining = append(remaining, match)
+		}
+	}
+	ls.ambiguousMatches = remaining
+}
+
+// lookupDriverForNewCarConnection finds the driver for a new car connection and reports whether another pending
+// connection request could have been the right one
+//
+// The log does not tell which connection a new car belongs to. A player reconnecting to a car they already drove is
+// the best match. Otherwise a car connection is created right after the connection request has been accepted, so the
+// most recent pending request for the same car model is used.
+func (ls *LiveState) lookupDriverForNewCarConnection(carEvent logEventNewCarConnection) (*Driver, bool) {
+	ls.removeStaleConnectionRequests()
+	candidates := make([]*connectionRequest, 0)
+	returning := make([]*connectionRequest, 0)
+	carState := ls.CarState[carEvent.CarID]
+	for _, request := range ls.connectionRequests {
+		if request.CarModelID == carEvent.CarModelID {
+			candidates = append(candidates, request)
+			if carState != nil && carState.driverSlot(request.SteamID) >= 0 {
+				returning = append(returning, request)
 			}
-			ls.driverPerConnection[driver.ConnectionID] = driver
-			return driver
 		}
 	}
-	return nil
+	if len(candidates) == 0 {
+		return nil, false
+	}
+
+	request := candidates[len(candidates)-1]
+	ambiguous := len(candidates) > 1 || request.ambiguous
+	if len(returning) > 0 {
+		request = returning[len(returning)-1]
+		ambiguous = len(returning) > 1
+	}
+	ls.removeConnectionRequests(func(r *connectionRequest) bool {
+		return r == request
+	})
+	if ambiguous {
+		for _, candidate := range candidates {
+			candidate.ambiguous = true
+		}
+	}
+
+	driver := &Driver{
+		ConnectionID: request.ConnectionID,
+		Name:         request.PlayerName,
+		PlayerID:     request.SteamID,
+	}
+	ls.driverPerConnection[driver.ConnectionID] = driver
+	return driver, ambiguous
+}
+
+// addConnectedDriver adds the driver of a car connection to a car and returns whether the driver got a new slot
+func (ls *LiveState) addConnectedDriver(carState *CarState, driver *Driver) bool {
+	newSlot := carState.addDriverSlot(driver)
+	carState.addDriver(driver)
+	carState.addSessionDriver(driver)
+	ls.carPerConnection[driver.ConnectionID] = carState.CarID
+	return newSlot
+}
+
+// correctAmbiguousMatch swaps two ambiguous matches when a lap shows that a car is driven by a player who was matched
+// to another car of the same model
+func (ls *LiveState) correctAmbiguousMatch(carState *CarState, driverIndex int) {
+	if driverIndex < 0 || driverIndex >= len(carState.driverSlots) {
+		return
+	}
+	playerID := carState.driverSlots[driverIndex].PlayerID
+	if carState.isConnected(playerID) {
+		return
+	}
+
+	var own, other *ambiguousMatch
+	for _, match := range ls.ambiguousMatches {
+		if match.carID == carState.CarID {
+			own = match
+		} else if match.driver.PlayerID == playerID {
+			other = match
+		}
+	}
+	if own == nil || other == nil {
+		return
+	}
+	otherCarState := ls.CarState[other.carID]
+	if otherCarState == nil || otherCarState.CarModel != carState.CarModel {
+		return
+	}
+
+	carState.undoMatch(own)
+	otherCarState.undoMatch(other)
+	ls.addConnectedDriver(carState, other.driver)
+	ls.addConnectedDriver(otherCarState, own.driver)
+	ls.removeAmbiguousMatches(func(match *ambiguousMatch) bool {
+		return match == own || match == other
+	})
+	ls.setCarState(otherCarState)
+}
+
+// driverSlot returns the index of the driver slot of a player, or -1 if the player never joined the car
+func (carState *CarState) driverSlot(playerID string) int {
+	for i, driver := range carState.driverSlots {
+		if driver.PlayerID == playerID {
+			return i
+		}
+	}
+	return -1
+}
+
+// addDriverSlot gives a driver the slot of an earlier connection of the same player, or a new slot if the player did
+// not join the car before; it returns whether a new slot was added
+func (carState *CarState) addDriverSlot(driver *Driver) bool {
+	if i := carState.driverSlot(driver.PlayerID); i >= 0 {
+		carState.driverSlots[i] = driver
+		return false
+	}
+	carState.driverSlots = append(carState.driverSlots, driver)
+	return true
+}
+
+// isConnected checks if a player is currently connected to the car
+func (carState *CarState) isConnected(playerID string) bool {
+	for _, driver := range carState.Drivers {
+		if driver.PlayerID == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+// undoMatch removes a driver which was wrongly added to the car by an ambiguous match
+func (carState *CarState) undoMatch(match *ambiguousMatch) {
+	carState.removeDriver(match.driver)
+	if carState.CurrentDriver == match.driver {
+		carState.CurrentDriver = nil
+	}
+	if match.newSlot {
+		if i := carState.driverSlot(match.driver.PlayerID); i >= 0 {
+			carState.driverSlots = append(carState.driverSlots[:i], carState.driverSlots[i+1:]...)
+		}
+	}
+	for i, driver := range carState.sessionDrivers {
+		if driver == match.driver {
+			carState.sessionDrivers = append(carState.sessionDrivers[:i], carState.sessionDrivers[i+1:]...)
+			break
+		}
+	}
+}
+
+// removeDriver removes a driver from the connected drivers of a car. If the driver was the current driver, another
+// connected driver becomes the current driver; if there is none, the current driver remains set to show who drove last.
+func (carState *CarState) removeDriver(driver *Driver) {
+	for i := 0; i < len(carState.Drivers); i++ {
+		if carState.Drivers[i] == driver {
+			copy(carState.Drivers[i:], carState.Drivers[i+1:])
+			carState.Drivers = carState.Drivers[:len(carState.Drivers)-1]
+			i--
+		}
+	}
+	if carState.CurrentDriver == driver && len(carState.Drivers) > 0 {
+		carState.CurrentDriver = carState.Drivers[0]
+	}
+}
+
+// addDriver adds a connected driver to a car, replacing an earlier connection of the same player
+func (carState *CarState) addDriver(driver *Driver) {
+	for i, existing := range carState.Drivers {
+		if existing.PlayerID == driver.PlayerID {
+			if carState.CurrentDriver == existing {
+				carState.CurrentDriver = driver
+			}
+			carState.Drivers[i] = driver
+			return
+		}
+	}
+	carState.Drivers = append(carState.Drivers, driver)
+	if carState.CurrentDriver == nil {
+		carState.CurrentDriver = driver
+	}
+}
+
+// driverForLap returns the driver who drove a lap according to the driver index in the log. ACC numbers the drivers
+// of a car in the order in which they joined. If the index is unknown, the current driver is assumed.
+func (carState *CarState) driverForLap(driverIndex int) *Driver {
+	if driverIndex >= 0 && driverIndex < len(carState.driverSlots) {
+		return carState.driverSlots[driverIndex]
+	}
+	return carState.CurrentDriver
+}
+
+// isNewSession checks if the change from the old to the new session state indicates a new session has started
+func isNewSession(oldState, newState *SessionState) bool {
+	if newState.Type != oldState.Type || (oldState.Index < 0 && !oldState.HasStarted()) {
+		return true
+	}
+	// A session of the same type starts again after the clock of the previous one has been running
+	return oldState.HasStarted() && newState.Phase != SessionPhaseSession &&
+		newState.Phase != SessionPhaseSessionOvertime && newState.Phase != SessionPhaseSessionCompleted
+}
+
+// matchConfiguredSession finds the configured session for a new session state, searching after the previous index
+func (ls *LiveState) matchConfiguredSession(state *SessionState, previousIndex int) {
+	state.Index = -1
+	state.DurationMinutes = 0
+	state.StartTime = time.Time{}
+
+	if ls.eventCfg == nil {
+		return
+	}
+
+	sessions := ls.eventCfg.Sessions
+	for i := 0; i < len(sessions); i++ {
+		index := (previousIndex + 1 + i) % len(sessions)
+		if liveSessionTypes[sessions[index].SessionType] == state.Type {
+			state.Index = index
+			state.DurationMinutes = sessions[index].SessionDurationMinutes
+			return
+		}
+	}
+}
+
+// addRecentLap records a lap time for the average lap time calculation
+func (car *CarState) addRecentLap(lapTimeMS int) {
+	car.recentLapsMS = append(car.recentLapsMS, lapTimeMS)
+	if len(car.recentLapsMS) > nrLapsForAverage {
+		car.recentLapsMS = car.recentLapsMS[len(car.recentLapsMS)-nrLapsForAverage:]
+	}
+
+	total := 0
+	for _, lap := range car.recentLapsMS {
+		total += lap
+	}
+	car.AverageLapMS = total / len(car.recentLapsMS)
+}
+
+// sessionBestLapMS returns the fastest lap of all cars in the current session, or 0 if there is none
+func (ls *LiveState) sessionBestLapMS() int {
+	best := 0
+	for _, car := range ls.CarState {
+		if car.BestLapMS > 0 && (best <= 0 || car.BestLapMS < best) {
+			best = car.BestLapMS
+		}
+	}
+	return best
+}
+
+// lapNotificationForNewLap determines which best lap times are improved by a new valid lap of a car, before the car
+// state is updated with the new lap. It returns nil if the lap did not improve any of them.
+func (ls *LiveState) lapNotificationForNewLap(carState *CarState, lapTimeMS int) *LapNotification {
+	notification := &LapNotification{
+		CarID:     carState.CarID,
+		Driver:    carState.CurrentDriver,
+		LapTimeMS: lapTimeMS,
+		Records:   make([]LapRecord, 0),
+	}
+
+	if carState.BestLapMS <= 0 || lapTimeMS < carState.BestLapMS {
+		notification.Records = append(notification.Records, LapRecordPersonalBest)
+	}
+	if sessionBest := ls.sessionBestLapMS(); sessionBest <= 0 || lapTimeMS < sessionBest {
+		notification.Records = append(notification.Records, LapRecordSessionBest)
+	}
+	carModel := -1
+	if carState.CarModel != nil {
+		carModel = carState.CarModel.ID
+	}
+	notification.Records = append(notification.Records, ls.lapRecords.historicRecords(lapTimeMS, ls.Track.Label, carModel, carState.CurrentDriver)...)
+
+	if len(notification.Records) == 0 {
+		return nil
+	}
+	return notification
 }
 
 func cmpPositionFastestLap(a, b *CarState) bool {
@@ -285,6 +742,10 @@ func (ls *LiveState) advanceSession() {
 			car.BestLapMS = 0
 			car.LastLapMS = 0
 			car.LastLapTimestampMS = 0
+			car.LastLapTime = time.Time{}
+			car.AverageLapMS = 0
+			car.EstimatedRemainingLaps = 0
+			car.recentLapsMS = nil
 			ls.setCarState(car)
 		}
 	}
@@ -293,11 +754,14 @@ func (ls *LiveState) advanceSession() {
 
 //--- Event reading and handling ---//
 
-func (ls *LiveState) newInstance(logEvents <-chan interface{}) {
+func (ls *LiveState) newInstance(logEvents <-chan interface{}, eventCfg *CfgEvent) {
 	if ls.stopMonitoring != nil {
 		ls.stopMonitoring <- true
 	}
 	ls.stopMonitoring = make(chan bool)
+	ls.eventCfg = eventCfg
+	ls.sessionLaps = make([]*recordedLap, 0)
+	ls.sessionRecordingStart = ls.now()
 
 	go ls.monitorEvents(logEvents, ls.stopMonitoring)
 }
@@ -314,6 +778,7 @@ func (ls *LiveState) monitorEvents(logEvents <-chan interface{}, stopMonitoring
 		case event, ok := <-logEvents:
 			if !ok {
 				if stopMonitoring != nil {
+					ls.endSession(ls.SessionState)
 					ls.serverOffline()
 				}
 				logEvents = nil
@@ -364,6 +829,9 @@ func (ls *LiveState) handleLogEvent(event interface{}) {
 }
 
 func (ls *LiveState) handleServerStarting(event logEventServerStarting) {
+	if ls.serverVersionDetected != nil {
+		ls.serverVersionDetected(event.Version)
+	}
 	ls.setServerState(ServerStateNotRegistered)
 }
 
@@ -376,7 +844,11 @@ func (ls *LiveState) handleLobbyConnectionFailed(event logEventLobbyConnectionFa
 }
 
 func (ls *LiveState) handleNrClientsOnline(event logEventNrClientsOnline) {
+	wasEmpty := ls.NrClients == 0
 	ls.setNrClients(event.NrClients)
+	if !wasEmpty && event.NrClients == 0 && ls.serverEmptied != nil {
+		ls.serverEmptied()
+	}
 }
 
 func (ls *LiveState) handleTrack(event logEventTrack) {
@@ -388,7 +860,21 @@ func (ls *LiveState) handleTrack(event logEventTrack) {
 
 func (ls *LiveState) handleSessionPhaseChanged(event logEventSessionPhaseChanged) {
 	oldState := ls.SessionState
-	newState := &SessionState{event.Type, event.Phase}
+	newState := &SessionState{
+		Type:            event.Type,
+		Phase:           event.Phase,
+		Index:           oldState.Index,
+		DurationMinutes: oldState.DurationMinutes,
+		StartTime:       oldState.StartTime,
+	}
+
+	if isNewSession(oldState, newState) {
+		ls.endSession(oldState)
+		ls.matchConfiguredSession(newState, oldState.Index)
+	}
+	if newState.Phase == SessionPhaseSession && !newState.HasStarted() {
+		newState.StartTime = ls.now()
+	}
 
 	ls.setSessionState(newState)
 
@@ -398,11 +884,27 @@ func (ls *LiveState) handleSessionPhaseChanged(event logEventSessionPhaseChanged
 }
 
 func (ls *LiveState) handleResettingWeekend(event logEventResettingWeekend) {
+	ls.endSession(ls.SessionState)
+	// The weekend starts again at the first configured session, which is matched on the next phase change
+	ls.setSessionState(&SessionState{
+		Type:  ls.SessionState.Type,
+		Phase: ls.SessionState.Phase,
+		Index: -1,
+	})
 	ls.advanceSession()
+	if ls.weekendReset != nil {
+		ls.weekendReset()
+	}
 }
 
 func (ls *LiveState) handleNewConnectionRequest(event logEventNewConnectionRequest) {
-	ls.connectionRequests = append(ls.connectionRequests, &event)
+	ls.removeStaleConnectionRequests()
+	// A connection ID is only used for a single request at a time
+	ls.removeConnectionRequests(func(request *connectionRequest) bool {
+		return request.ConnectionID == event.ConnectionID
+	})
+	request := &connectionRequest{logEventNewConnectionRequest: event, time: ls.now()}
+	ls.connectionRequests = append(ls.connectionRequests, request)
 }
 
 func (ls *LiveState) handleNewCarConnection(event logEventNewCarConnection) {
@@ -415,31 +917,29 @@ func (ls *LiveState) handleNewCarConnection(event logEventNewCarConnection) {
 	carState.RaceNumber = event.RaceNumber
 	carState.CarModel = accdata.CarModelByID(event.CarModelID)
 
-	if driver := ls.lookupDriverForNewCarConnection(event); driver != nil {
-		carState.Drivers = append(carState.Drivers, driver)
-		if carState.CurrentDriver == nil {
-			carState.CurrentDriver = driver
+	if driver, ambiguous := ls.lookupDriverForNewCarConnection(event); driver != nil {
+		newSlot := ls.addConnectedDriver(carState, driver)
+		if ambiguous {
+			ls.ambiguousMatches = append(ls.ambiguousMatches, &ambiguousMatch{carState.CarID, driver, newSlot})
 		}
-		ls.carPerConnection[driver.ConnectionID] = carState.CarID
 	}
 
 	ls.setCarState(carState)
 }
 
 func (ls *LiveState) handleDeadConnection(event logEventDeadConnection) {
-	// TODO: clean connection requests
+	ls.removeConnectionRequests(func(request *connectionRequest) bool {
+		return request.ConnectionID == event.ConnectionID
+	})
 
 	driver := ls.driverPerConnection[event.ConnectionID]
 	carID := ls.carPerConnection[event.ConnectionID]
+	ls.removeAmbiguousMatches(func(match *ambiguousMatch) bool {
+		return match.driver == driver
+	})
 
 	if carState := ls.CarState[carID]; carState != nil {
-		for i := 0; i < len(carState.Drivers); i++ {
-			if carState.Drivers[i] == driver {
-				copy(carState.Drivers[i:], carState.Drivers[i+1:])
-				carState.Drivers = carState.Drivers[:len(carState.Drivers)-1]
-				i--
-			}
-		}
+		carState.removeDriver(driver)
 		ls.setCarState(carState)
 	}
 
@@ -458,9 +958,30 @@ func (ls *LiveState) handleCarPurged(event logEventCarPurged) {
 
 func (ls *LiveState) handleNewLapTime(event logEventNewLapTime) {
 	if carState := ls.CarState[event.CarID]; carState != nil {
+		// The lap tells who is driving, which changes on a driver swap
+		ls.correctAmbiguousMatch(carState, event.DriverIndex)
+		carState.CurrentDriver = carState.driverForLap(event.DriverIndex)
+		if event.Flags == 0 {
+			if notification := ls.lapNotificationForNewLap(carState, event.LapTimeMS); notification != nil {
+				ls.notifyLap(notification)
+			}
+		}
+		ls.sessionLaps = append(ls.sessionLaps, &recordedLap{
+			carID:       carState.CarID,
+			driver:      carState.CurrentDriver,
+			lapTimeMS:   event.LapTimeMS,
+			timestampMS: event.TimestampMS,
+			flags:       event.Flags,
+		})
+		ls.recordHotlap(carState, event)
 		carState.NrLaps++
 		carState.LastLapMS = event.LapTimeMS
 		carState.LastLapTimestampMS = event.TimestampMS
+		carState.LastLapTime = ls.now()
+		if event.Flags&(flagLapIsInLap|flagLapIsOutLap) == 0 {
+			carState.addRecentLap(event.LapTimeMS)
+		}
+		carState.EstimatedRemainingLaps = ls.estimateRemainingLaps(carState)
 		if event.Flags == 0 && (carState.BestLapMS <= 0 || event.LapTimeMS < carState.BestLapMS) {
 			carState.BestLapMS = event.LapTimeMS
 		}
@@ -469,6 +990,23 @@ func (ls *LiveState) handleNewLapTime(event logEventNewLapTime) {
 	}
 }
 
+// recordHotlap adds a lap to the hotlap leaderboards, if enabled. In and out laps are skipped, because their time
+// includes driving through the pit lane.
+func (ls *LiveState) recordHotlap(carState *CarState, event logEventNewLapTime) {
+	if ls.hotlaps == nil || carState.CurrentDriver == nil || carState.CarModel == nil ||
+		event.Flags&(flagLapHasCut|flagLapIsInLap|flagLapIsOutLap) != 0 {
+		return
+	}
+	ls.hotlaps.RecordLap(&Hotlap{
+		TrackName:  ls.Track.Label,
+		CarModel:   carState.CarModel.ID,
+		PlayerID:   carState.CurrentDriver.PlayerID,
+		PlayerName: carState.CurrentDriver.Name,
+		LapTimeMS:  event.LapTimeMS,
+		Time:       ls.now(),
+	})
+}
+
 func (ls *LiveState) handleGridPosition(event logEventGridPosition) {
 	if carState := ls.CarState[event.CarID]; carState != nil {
 		carState.Position = event.Position
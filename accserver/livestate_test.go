@@ -4,8 +4,10 @@ import (
 	"log"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/geniusdex/racce/accdata"
+	"github.com/geniusdex/racce/accresults"
 
 	"net/http"
 	_ "net/http/pprof"
@@ -25,6 +27,18 @@ type testLiveStateFixture struct {
 	state     *LiveState
 	logEvents chan interface{}
 	events    *LiveStateEvents
+	now       time.Time
+}
+
+func newTestEventCfg() *CfgEvent {
+	return &CfgEvent{
+		Track: "zandvoort",
+		Sessions: []*CfgEventSession{
+			{SessionType: Practice, SessionDurationMinutes: 10},
+			{SessionType: Qualifying, SessionDurationMinutes: 15},
+			{SessionType: Race, SessionDurationMinutes: 20},
+		},
+	}
 }
 
 func newTestLiveStateFixture(t *testing.T) *testLiveStateFixture {
@@ -35,9 +49,11 @@ func newTestLiveStateFixture(t *testing.T) *testLiveStateFixture {
 		state:     state,
 		logEvents: make(chan interface{}),
 		events:    state.NewEventChannels(),
+		now:       time.Date(2020, 6, 1, 20, 0, 0, 0, time.UTC),
 	}
 
-	f.state.newInstance(f.logEvents)
+	f.state.now = func() time.Time { return f.now }
+	f.state.newInstance(f.logEvents, newTestEventCfg())
 	// Eat initial events always sent out
 	<-f.events.ServerState
 	<-f.events.NrClients
@@ -61,7 +77,7 @@ func TestLiveState_ServerState_Lifecycle(t *testing.T) {
 	events := state.NewEventChannels()
 
 	logEvents := make(chan interface{})
-	state.newInstance(logEvents)
+	state.newInstance(logEvents, nil)
 	assert.Equal(ServerStateStarting, <-events.ServerState)
 	assert.Equal(ServerStateStarting, state.ServerState)
 	assert.False(state.IsRunning())
@@ -84,12 +100,28 @@ func TestLiveState_ServerState_Lifecycle(t *testing.T) {
 	assert.False(state.IsRunning())
 }
 
+func TestLiveState_ServerVersionDetected(t *testing.T) {
+	state := newLiveState()
+	events := state.NewEventChannels()
+	versions := make([]int, 0)
+	state.serverVersionDetected = func(version int) { versions = append(versions, version) }
+
+	logEvents := make(chan interface{})
+	state.newInstance(logEvents, nil)
+	<-events.ServerState
+	<-events.NrClients
+
+	logEvents <- logEventServerStarting{Version: 255}
+	assert.Equal(t, ServerStateNotRegistered, <-events.ServerState)
+	assert.Equal(t, []int{255}, versions)
+}
+
 func TestLiveState_ServerState_NewInstance(t *testing.T) {
 	f := newTestLiveStateFixture(t)
 
 	// Server state should only respond to logEvents2 from now on
 	logEvents2 := make(chan interface{})
-	f.state.newInstance(logEvents2)
+	f.state.newInstance(logEvents2, nil)
 	assert.Equal(t, ServerStateStarting, <-f.events.ServerState)
 	assert.Equal(t, 0, <-f.events.NrClients)
 
@@ -143,12 +175,96 @@ func TestLiveState_SessionPhase(t *testing.T) {
 	f := newTestLiveStateFixture(t)
 
 	f.logEvents <- logEventSessionPhaseChanged{"Qualifying", "session"}
-	assert.Equal(t, &SessionState{SessionTypeQualifying, SessionPhaseSession}, <-f.events.SessionState)
+	state := <-f.events.SessionState
+	assert.Equal(t, SessionTypeQualifying, state.Type)
+	assert.Equal(t, SessionPhaseSession, state.Phase)
 	assert.Equal(t, SessionTypeQualifying, f.state.SessionState.Type)
 	assert.Equal(t, SessionPhaseSession, f.state.SessionState.Phase)
 }
 
+func TestLiveState_SessionClock(t *testing.T) {
+	f := newTestLiveStateFixture(t)
+
+	f.logEvents <- logEventSessionPhaseChanged{"Qualifying", "pre session"}
+	state := <-f.events.SessionState
+	assert.Equal(t, 1, state.Index)
+	assert.Equal(t, 15, state.DurationMinutes)
+	assert.False(t, state.HasStarted())
+	assert.Equal(t, &SessionClock{false, 0, 0}, state.Clock)
+
+	startTime := f.now
+	f.logEvents <- logEventSessionPhaseChanged{"Qualifying", "session"}
+	state = <-f.events.SessionState
+	assert.Equal(t, 1, state.Index)
+	assert.Equal(t, startTime, state.StartTime)
+	assert.Equal(t, &SessionClock{true, 0, (15 * time.Minute).Milliseconds()}, state.Clock)
+
+	f.now = startTime.Add(5 * time.Minute)
+	current := f.state.CurrentSessionState()
+	assert.Equal(t, &SessionClock{true, (5 * time.Minute).Milliseconds(), (10 * time.Minute).Milliseconds()}, current.Clock)
+	assert.Equal(t, startTime, current.StartTime)
+
+	f.now = startTime.Add(16 * time.Minute)
+	f.logEvents <- logEventSessionPhaseChanged{"Qualifying", "session overtime"}
+	state = <-f.events.SessionState
+	assert.Equal(t, startTime, state.StartTime)
+	assert.Equal(t, &SessionClock{true, (16 * time.Minute).Milliseconds(), 0}, state.Clock)
+
+	f.logEvents <- logEventSessionPhaseChanged{"Race", "pre session"}
+	state = <-f.events.SessionState
+	assert.Equal(t, 2, state.Index)
+	assert.Equal(t, 20, state.DurationMinutes)
+	assert.False(t, state.HasStarted())
+}
+
+func TestLiveState_SessionClock_SameTypeTwice(t *testing.T) {
+	f := newTestLiveStateFixture(t)
+	f.state.eventCfg.Sessions = append(f.state.eventCfg.Sessions, &CfgEventSession{SessionType: Race, SessionDurationMinutes: 25})
+
+	f.logEvents <- logEventSessionPhaseChanged{"Race", "session"}
+	state := <-f.events.SessionState
+	assert.Equal(t, 2, state.Index)
+
+	f.logEvents <- logEventSessionPhaseChanged{"Race", "session completed"}
+	state = <-f.events.SessionState
+	assert.Equal(t, 2, state.Index)
+
+	f.logEvents <- logEventSessionPhaseChanged{"Race", "pre session"}
+	state = <-f.events.SessionState
+	assert.Equal(t, 3, state.Index)
+	assert.Equal(t, 25, state.DurationMinutes)
+}
+
+func TestLiveState_SessionClock_ResettingWeekend(t *testing.T) {
+	f := newTestLiveStateFixture(t)
+
+	f.logEvents <- logEventSessionPhaseChanged{"Qualifying", "session"}
+	assert.Equal(t, 1, (<-f.events.SessionState).Index)
+
+	f.logEvents <- logEventResettingWeekend{}
+	state := <-f.events.SessionState
+	assert.Equal(t, -1, state.Index)
+	assert.False(t, state.HasStarted())
+
+	f.logEvents <- logEventSessionPhaseChanged{"Qualifying", "pre session"}
+	state = <-f.events.SessionState
+	assert.Equal(t, 1, state.Index)
+	assert.False(t, state.HasStarted())
+
+	f.logEvents <- logEventSessionPhaseChanged{"Practice", "pre session"}
+	assert.Equal(t, 0, (<-f.events.SessionState).Index)
+}
+
 //--- Car Updates ---//
+// playerIDs returns the player IDs of a list of drivers
+func playerIDs(drivers []*Driver) []string {
+	ids := make([]string, 0, len(drivers))
+	for _, driver := range drivers {
+		ids = append(ids, driver.PlayerID)
+	}
+	return ids
+}
+
 func TestLiveState_NewCar(t *testing.T) {
 	f := newTestLiveStateFixture(t)
 
@@ -166,6 +282,9 @@ func TestLiveState_NewCar(t *testing.T) {
 		Drivers:       []*Driver{driver},
 		CurrentDriver: driver,
 		Position:      1,
+
+		sessionDrivers: []*Driver{driver},
+		driverSlots:    []*Driver{driver},
 	}
 	assert.Equal(t, carState, <-f.events.CarState)
 	assert.Equal(t, carState, f.state.CarState[1001])
@@ -194,11 +313,225 @@ func TestLiveState_UseMostRecentConnectionRequestForNewCar(t *testing.T) {
 		Drivers:       []*Driver{driver},
 		CurrentDriver: driver,
 		Position:      1,
+
+		sessionDrivers: []*Driver{driver},
+		driverSlots:    []*Driver{driver},
 	}
 	assert.Equal(t, carState, <-f.events.CarState)
 	assert.Equal(t, carState, f.state.CarState[1001])
 }
 
+func TestLiveState_ConnectionRequestsForSameCarModel(t *testing.T) {
+	f := newTestLiveStateFixture(t)
+
+	f.logEvents <- logEventNewConnectionRequest{1, "Driver One", "S1", 24}
+	f.logEvents <- logEventNewCarConnection{1001, 24, 42}
+	carState := <-f.events.CarState
+	assert.Equal(t, "S1", carState.CurrentDriver.PlayerID)
+
+	f.logEvents <- logEventNewConnectionRequest{2, "Driver Two", "S2", 24}
+	f.logEvents <- logEventNewCarConnection{1002, 24, 43}
+	carState = <-f.events.CarState
+	assert.Equal(t, "S2", carState.CurrentDriver.PlayerID)
+
+	assert.Len(t, f.state.CarState[1001].Drivers, 1)
+	assert.Len(t, f.state.CarState[1002].Drivers, 1)
+	assert.Empty(t, f.state.connectionRequests)
+}
+
+func TestLiveState_SimultaneousConnectionRequestsPreferReturningDriver(t *testing.T) {
+	f := newTestLiveStateFixture(t)
+
+	f.logEvents <- logEventNewConnectionRequest{1, "Driver One", "S1", 24}
+	f.logEvents <- logEventNewCarConnection{1001, 24, 42}
+	<-f.events.CarState
+	f.logEvents <- logEventDeadConnection{1}
+	<-f.events.CarState
+
+	// Both requests are pending when the cars connect
+	f.logEvents <- logEventNewConnectionRequest{3, "Driver One", "S1", 24}
+	f.logEvents <- logEventNewConnectionRequest{4, "Driver Two", "S2", 24}
+	f.logEvents <- logEventNewCarConnection{1001, 24, 42}
+	carState := <-f.events.CarState
+	if assert.Len(t, carState.Drivers, 1) {
+		assert.Equal(t, "S1", carState.Drivers[0].PlayerID)
+	}
+	f.logEvents <- logEventNewCarConnection{1002, 24, 43}
+	carState = <-f.events.CarState
+	if assert.Len(t, carState.Drivers, 1) {
+		assert.Equal(t, "S2", carState.Drivers[0].PlayerID)
+	}
+	assert.Empty(t, f.state.ambiguousMatches)
+}
+
+func TestLiveState_SimultaneousConnectionRequestsCorrectedByLap(t *testing.T) {
+	f := newTestLiveStateFixture(t)
+
+	f.logEvents <- logEventNewConnectionRequest{1, "Driver One", "S1", 24}
+	f.logEvents <- logEventNewCarConnection{1001, 24, 42}
+	<-f.events.CarState
+	f.logEvents <- logEventNewConnectionRequest{2, "Driver Two", "S2", 24}
+	f.logEvents <- logEventNewCarConnection{1001, 24, 42}
+	<-f.events.CarState
+	f.logEvents <- logEventDeadConnection{2}
+	<-f.events.CarState
+
+	// Both requests are pending; the new car 1002 connects after car 1001, so it cannot be told apart from a returning
+	// driver of car 1001 and the most recent request is used for car 1001
+	f.logEvents <- logEventNewConnectionRequest{3, "Driver Three", "S3", 24}
+	f.logEvents <- logEventNewConnectionRequest{4, "Driver Two", "S2", 24}
+	f.logEvents <- logEventNewConnectionRequest{5, "Driver Four", "S4", 24}
+	f.logEvents <- logEventDeadConnection{5}
+	f.logEvents <- logEventNewCarConnection{1002, 24, 43}
+	carState := <-f.events.CarState
+	assert.Equal(t, "S2", carState.CurrentDriver.PlayerID)
+	f.logEvents <- logEventNewCarConnection{1001, 24, 42}
+	carState = <-f.events.CarState
+	assert.Equal(t, []string{"S1", "S3"}, playerIDs(carState.Drivers))
+	assert.Len(t, f.state.ambiguousMatches, 2)
+
+	// The lap of driver 1 in car 1001 shows S2 is driving it, so the matches are swapped
+	f.logEvents <- logEventNewLapTime{1001, 1, 130000, 100, flagLapIsOutLap}
+	carState = <-f.events.CarState
+	assert.Equal(t, 1002, carState.CarID)
+	assert.Equal(t, []string{"S3"}, playerIDs(carState.Drivers))
+	assert.Equal(t, []string{"S3"}, playerIDs(carState.driverSlots))
+	assert.Equal(t, "S3", carState.CurrentDriver.PlayerID)
+	assert.Equal(t, 3, carState.CurrentDriver.ConnectionID)
+
+	carState = <-f.events.CarState
+	assert.Equal(t, 1001, carState.CarID)
+	assert.Equal(t, []string{"S1", "S2"}, playerIDs(carState.Drivers))
+	assert.Equal(t, []string{"S1", "S2"}, playerIDs(carState.driverSlots))
+	assert.Equal(t, []string{"S1", "S2"}, playerIDs(carState.sessionDrivers))
+	assert.Equal(t, 4, carState.CurrentDriver.ConnectionID)
+	assert.Empty(t, f.state.ambiguousMatches)
+	assert.Equal(t, 1001, f.state.carPerConnection[4])
+	assert.Equal(t, 1002, f.state.carPerConnection[3])
+}
+
+func TestLiveState_StaleConnectionRequestIgnored(t *testing.T) {
+	f := newTestLiveStateFixture(t)
+
+	// This request is rejected by the server, so no car connection follows
+	f.logEvents <- logEventNewConnectionRequest{1, "Driver One", "S1", 24}
+	f.logEvents <- logEventNrClientsOnline{1}
+	<-f.events.NrClients
+	f.now = f.now.Add(time.Minute)
+
+	f.logEvents <- logEventNewCarConnection{1001, 24, 42}
+	carState := <-f.events.CarState
+	assert.Empty(t, carState.Drivers)
+	assert.Nil(t, carState.CurrentDriver)
+	assert.Empty(t, f.state.connectionRequests)
+}
+
+func TestLiveState_ConnectionRequestRemovedOnDeadConnection(t *testing.T) {
+	f := newTestLiveStateFixture(t)
+
+	f.logEvents <- logEventNewConnectionRequest{1, "Driver One", "S1", 24}
+	f.logEvents <- logEventDeadConnection{1}
+	f.logEvents <- logEventNewConnectionRequest{2, "Driver Two", "S2", 25}
+	f.logEvents <- logEventNewCarConnection{1001, 24, 42}
+	carState := <-f.events.CarState
+	assert.Empty(t, carState.Drivers)
+	assert.Len(t, f.state.connectionRequests, 1)
+}
+
+func TestLiveState_ConnectionRequestReplacedForSameConnection(t *testing.T) {
+	f := newTestLiveStateFixture(t)
+
+	f.logEvents <- logEventNewConnectionRequest{1, "Driver One", "S1", 24}
+	f.logEvents <- logEventNewConnectionRequest{2, "Driver Two", "S2", 24}
+	f.logEvents <- logEventNewConnectionRequest{1, "Driver Three", "S3", 25}
+	f.logEvents <- logEventNewCarConnection{1001, 24, 42}
+	carState := <-f.events.CarState
+	assert.Equal(t, "S2", carState.CurrentDriver.PlayerID)
+
+	f.logEvents <- logEventNewCarConnection{1002, 24, 43}
+	carState = <-f.events.CarState
+	assert.Empty(t, carState.Drivers)
+}
+
+func TestLiveState_DriverSwap(t *testing.T) {
+	f := newTestLiveStateFixture(t)
+
+	f.logEvents <- logEventNewConnectionRequest{1, "Driver One", "S1", 24}
+	f.logEvents <- logEventNewCarConnection{1001, 24, 42}
+	<-f.events.CarState
+	f.logEvents <- logEventNewConnectionRequest{2, "Driver Two", "S2", 24}
+	f.logEvents <- logEventNewCarConnection{1001, 24, 42}
+	carState := <-f.events.CarState
+	assert.Len(t, carState.Drivers, 2)
+	assert.Equal(t, "S1", carState.CurrentDriver.PlayerID)
+
+	f.logEvents <- logEventNewLapTime{1001, 1, 130000, 100, flagLapIsOutLap}
+	carState = <-f.events.CarState
+	assert.Equal(t, "S2", carState.CurrentDriver.PlayerID)
+
+	f.logEvents <- logEventNewLapTime{1001, 0, 131000, 200, flagLapIsInLap}
+	carState = <-f.events.CarState
+	assert.Equal(t, "S1", carState.CurrentDriver.PlayerID)
+
+	// An unknown driver index keeps the current driver
+	f.logEvents <- logEventNewLapTime{1001, 5, 132000, 300, flagLapIsInLap}
+	carState = <-f.events.CarState
+	assert.Equal(t, "S1", carState.CurrentDriver.PlayerID)
+
+	// The teammate takes over when the current driver disconnects
+	f.logEvents <- logEventDeadConnection{1}
+	carState = <-f.events.CarState
+	assert.Len(t, carState.Drivers, 1)
+	assert.Equal(t, "S2", carState.CurrentDriver.PlayerID)
+
+	// The last driver remains current after disconnecting
+	f.logEvents <- logEventDeadConnection{2}
+	carState = <-f.events.CarState
+	assert.Empty(t, carState.Drivers)
+	assert.Equal(t, "S2", carState.CurrentDriver.PlayerID)
+}
+
+func TestLiveState_DriverReconnects(t *testing.T) {
+	f := newTestLiveStateFixture(t)
+
+	f.logEvents <- logEventNewConnectionRequest{1, "Driver One", "S1", 24}
+	f.logEvents <- logEventNewCarConnection{1001, 24, 42}
+	<-f.events.CarState
+	f.logEvents <- logEventNewConnectionRequest{3, "Driver One", "S1", 24}
+	f.logEvents <- logEventNewCarConnection{1001, 24, 42}
+	carState := <-f.events.CarState
+	if assert.Len(t, carState.Drivers, 1) {
+		assert.Equal(t, 3, carState.Drivers[0].ConnectionID)
+	}
+	assert.Equal(t, 3, carState.CurrentDriver.ConnectionID)
+}
+
+func TestLiveState_DriverReconnectsBeforeLap(t *testing.T) {
+	f := newTestLiveStateFixture(t)
+
+	f.logEvents <- logEventNewConnectionRequest{1, "Driver One", "S1", 24}
+	f.logEvents <- logEventNewCarConnection{1001, 24, 42}
+	<-f.events.CarState
+	f.logEvents <- logEventNewConnectionRequest{2, "Driver Two", "S2", 24}
+	f.logEvents <- logEventNewCarConnection{1001, 24, 42}
+	<-f.events.CarState
+	f.logEvents <- logEventDeadConnection{1}
+	<-f.events.CarState
+	f.logEvents <- logEventNewConnectionRequest{3, "Driver One", "S1", 24}
+	f.logEvents <- logEventNewCarConnection{1001, 24, 42}
+	carState := <-f.events.CarState
+	assert.Equal(t, []string{"S2", "S1"}, playerIDs(carState.Drivers))
+
+	// Driver IDs keep following the order in which the drivers first joined
+	f.logEvents <- logEventNewLapTime{1001, 0, 130000, 100, flagLapIsOutLap}
+	carState = <-f.events.CarState
+	assert.Equal(t, 3, carState.CurrentDriver.ConnectionID)
+
+	f.logEvents <- logEventNewLapTime{1001, 1, 131000, 200, flagLapIsInLap}
+	carState = <-f.events.CarState
+	assert.Equal(t, "S2", carState.CurrentDriver.PlayerID)
+}
+
 func TestLiveState_CarPurged(t *testing.T) {
 	f := newTestLiveStateFixture(t)
 
@@ -286,6 +619,7 @@ func TestLiveState_CarsWithoutDriversPurgedWhenResettingRaceWeekend(t *testing.T
 	<-f.events.CarState
 
 	f.logEvents <- logEventResettingWeekend{}
+	<-f.events.SessionState
 
 	go func() { <-f.events.CarState }() // Eat car state update for 1002
 	assert.Equal(t, 1004, <-f.events.CarPurged)
@@ -314,7 +648,8 @@ func TestLiveState_NewLapTime(t *testing.T) {
 	f.logEvents <- logEventNewCarConnection{1002, 5, 42}
 	assert.NotNil(t, <-f.events.CarState)
 
-	f.logEvents <- logEventNewLapTime{1002, 123456, 100, 0}
+	f.logEvents <- logEventNewLapTime{1002, 0, 123456, 100, 0}
+	assert.NotNil(t, <-f.events.Lap)
 	carState := <-f.events.CarState
 	assert.Equal(t, 123456, carState.BestLapMS)
 	assert.Equal(t, 1, carState.NrLaps)
@@ -322,49 +657,50 @@ func TestLiveState_NewLapTime(t *testing.T) {
 	assert.Equal(t, 100, carState.LastLapTimestampMS)
 	assert.Equal(t, carState, f.state.CarState[1002])
 
-	f.logEvents <- logEventNewLapTime{1002, 123457, 101, 0}
+	f.logEvents <- logEventNewLapTime{1002, 0, 123457, 101, 0}
 	carState = <-f.events.CarState
 	assert.Equal(t, 123456, carState.BestLapMS)
 	assert.Equal(t, 2, carState.NrLaps)
 	assert.Equal(t, 123457, carState.LastLapMS)
 	assert.Equal(t, 101, carState.LastLapTimestampMS)
 
-	f.logEvents <- logEventNewLapTime{1002, 123000, 102, 1}
+	f.logEvents <- logEventNewLapTime{1002, 0, 123000, 102, 1}
 	carState = <-f.events.CarState
 	assert.Equal(t, 123456, carState.BestLapMS)
 	assert.Equal(t, 3, carState.NrLaps)
 	assert.Equal(t, 123000, carState.LastLapMS)
 	assert.Equal(t, 102, carState.LastLapTimestampMS)
 
-	f.logEvents <- logEventNewLapTime{1002, 123001, 103, 4}
+	f.logEvents <- logEventNewLapTime{1002, 0, 123001, 103, 4}
 	carState = <-f.events.CarState
 	assert.Equal(t, 123456, carState.BestLapMS)
 	assert.Equal(t, 4, carState.NrLaps)
 	assert.Equal(t, 123001, carState.LastLapMS)
 	assert.Equal(t, 103, carState.LastLapTimestampMS)
 
-	f.logEvents <- logEventNewLapTime{1002, 123002, 104, 8}
+	f.logEvents <- logEventNewLapTime{1002, 0, 123002, 104, 8}
 	carState = <-f.events.CarState
 	assert.Equal(t, 123456, carState.BestLapMS)
 	assert.Equal(t, 5, carState.NrLaps)
 	assert.Equal(t, 123002, carState.LastLapMS)
 	assert.Equal(t, 104, carState.LastLapTimestampMS)
 
-	f.logEvents <- logEventNewLapTime{1002, 123003, 105, 13}
+	f.logEvents <- logEventNewLapTime{1002, 0, 123003, 105, 13}
 	carState = <-f.events.CarState
 	assert.Equal(t, 123456, carState.BestLapMS)
 	assert.Equal(t, 6, carState.NrLaps)
 	assert.Equal(t, 123003, carState.LastLapMS)
 	assert.Equal(t, 105, carState.LastLapTimestampMS)
 
-	f.logEvents <- logEventNewLapTime{1002, 123004, 106, 1024}
+	f.logEvents <- logEventNewLapTime{1002, 0, 123004, 106, 1024}
 	carState = <-f.events.CarState
 	assert.Equal(t, 123456, carState.BestLapMS)
 	assert.Equal(t, 7, carState.NrLaps)
 	assert.Equal(t, 123004, carState.LastLapMS)
 	assert.Equal(t, 106, carState.LastLapTimestampMS)
 
-	f.logEvents <- logEventNewLapTime{1002, 123400, 107, 0}
+	f.logEvents <- logEventNewLapTime{1002, 0, 123400, 107, 0}
+	assert.NotNil(t, <-f.events.Lap)
 	carState = <-f.events.CarState
 	assert.Equal(t, 123400, carState.BestLapMS)
 	assert.Equal(t, 8, carState.NrLaps)
@@ -383,7 +719,8 @@ func TestLiveState_LapsRemovedWhenSessionTypeChanges(t *testing.T) {
 	f.logEvents <- logEventNewCarConnection{1002, 5, 42}
 	assert.NotNil(t, <-f.events.CarState)
 
-	f.logEvents <- logEventNewLapTime{1002, 123456, 100, 0}
+	f.logEvents <- logEventNewLapTime{1002, 0, 123456, 100, 0}
+	assert.NotNil(t, <-f.events.Lap)
 	carState := <-f.events.CarState
 	assert.Equal(t, 123456, carState.BestLapMS)
 	assert.Equal(t, 1, carState.NrLaps)
@@ -418,24 +755,27 @@ func TestLiveState_PositionDuringQualifying(t *testing.T) {
 	assert.Equal(t, 1, f.state.CarState[1002].Position)
 	assert.Equal(t, 2, f.state.CarState[1004].Position)
 
-	f.logEvents <- logEventNewLapTime{1002, 123050, 100, 0}
+	f.logEvents <- logEventNewLapTime{1002, 0, 123050, 100, 0}
+	assert.NotNil(t, <-f.events.Lap)
 	<-f.events.CarState
 	assert.Equal(t, 1, f.state.CarState[1002].Position)
 	assert.Equal(t, 2, f.state.CarState[1004].Position)
 
-	f.logEvents <- logEventNewLapTime{1004, 123040, 101, 0}
+	f.logEvents <- logEventNewLapTime{1004, 0, 123040, 101, 0}
+	assert.NotNil(t, <-f.events.Lap)
 	<-f.events.CarState
 	<-f.events.CarState
 	<-f.events.CarState
 	assert.Equal(t, 2, f.state.CarState[1002].Position)
 	assert.Equal(t, 1, f.state.CarState[1004].Position)
 
-	f.logEvents <- logEventNewLapTime{1004, 123060, 102, 0}
+	f.logEvents <- logEventNewLapTime{1004, 0, 123060, 102, 0}
 	<-f.events.CarState
 	assert.Equal(t, 2, f.state.CarState[1002].Position)
 	assert.Equal(t, 1, f.state.CarState[1004].Position)
 
-	f.logEvents <- logEventNewLapTime{1002, 123030, 102, 0}
+	f.logEvents <- logEventNewLapTime{1002, 0, 123030, 102, 0}
+	assert.NotNil(t, <-f.events.Lap)
 	<-f.events.CarState
 	<-f.events.CarState
 	<-f.events.CarState
@@ -460,25 +800,217 @@ func TestLiveState_PositionDuringRace(t *testing.T) {
 	assert.Equal(t, 1, f.state.CarState[1002].Position)
 	assert.Equal(t, 2, f.state.CarState[1004].Position)
 
-	f.logEvents <- logEventNewLapTime{1002, 123050, 100, 0}
+	f.logEvents <- logEventNewLapTime{1002, 0, 123050, 100, 0}
+	assert.NotNil(t, <-f.events.Lap)
 	<-f.events.CarState
 	assert.Equal(t, 1, f.state.CarState[1002].Position)
 	assert.Equal(t, 2, f.state.CarState[1004].Position)
 
-	f.logEvents <- logEventNewLapTime{1004, 123040, 101, 0}
+	f.logEvents <- logEventNewLapTime{1004, 0, 123040, 101, 0}
+	assert.NotNil(t, <-f.events.Lap)
 	<-f.events.CarState
 	assert.Equal(t, 1, f.state.CarState[1002].Position)
 	assert.Equal(t, 2, f.state.CarState[1004].Position)
 
-	f.logEvents <- logEventNewLapTime{1004, 123060, 102, 0}
+	f.logEvents <- logEventNewLapTime{1004, 0, 123060, 102, 0}
 	<-f.events.CarState
 	<-f.events.CarState
 	<-f.events.CarState
 	assert.Equal(t, 2, f.state.CarState[1002].Position)
 	assert.Equal(t, 1, f.state.CarState[1004].Position)
 
-	f.logEvents <- logEventNewLapTime{1002, 123030, 102, 0}
+	f.logEvents <- logEventNewLapTime{1002, 0, 123030, 102, 0}
+	assert.NotNil(t, <-f.events.Lap)
 	<-f.events.CarState
 	assert.Equal(t, 2, f.state.CarState[1002].Position)
 	assert.Equal(t, 1, f.state.CarState[1004].Position)
 }
+
+func TestLiveState_EstimatedRemainingLaps(t *testing.T) {
+	f := newTestLiveStateFixture(t)
+
+	startTime := f.now
+	f.logEvents <- logEventSessionPhaseChanged{"Race", "session"}
+	<-f.events.SessionState
+
+	f.logEvents <- logEventNewConnectionRequest{6, "Driver One", "S76543210987654321", 5}
+	f.logEvents <- logEventNewCarConnection{1002, 5, 42}
+	<-f.events.CarState
+	car := f.state.CarState[1002]
+	assert.Equal(t, 0, car.EstimatedRemainingLaps)
+
+	f.now = startTime.Add(130 * time.Second)
+	f.logEvents <- logEventNewLapTime{1002, 0, 130000, 130000, flagLapIsOutLap}
+	<-f.events.CarState
+	assert.Equal(t, 0, car.AverageLapMS)
+	assert.Equal(t, 0, car.EstimatedRemainingLaps)
+
+	f.now = startTime.Add(250 * time.Second)
+	f.logEvents <- logEventNewLapTime{1002, 0, 120000, 250000, 0}
+	assert.NotNil(t, <-f.events.Lap)
+	<-f.events.CarState
+	assert.Equal(t, 120000, f.state.CarState[1002].AverageLapMS)
+	assert.Equal(t, f.now, f.state.CarState[1002].LastLapTime)
+	// 950 seconds remaining at 120 seconds per lap
+	assert.Equal(t, 8, car.EstimatedRemainingLaps)
+
+	f.now = startTime.Add(370 * time.Second)
+	f.logEvents <- logEventNewLapTime{1002, 0, 118000, 370000, 0}
+	assert.NotNil(t, <-f.events.Lap)
+	<-f.events.CarState
+	assert.Equal(t, 119000, car.AverageLapMS)
+	// 830 seconds remaining at 119 seconds per lap
+	assert.Equal(t, 7, car.EstimatedRemainingLaps)
+
+	// 30 seconds into a lap with 800 seconds remaining
+	f.now = startTime.Add(400 * time.Second)
+	assert.Equal(t, 7, f.state.estimateRemainingLaps(car))
+
+	// Time is up, but the current lap still has to be finished
+	f.now = startTime.Add(1210 * time.Second)
+	assert.Equal(t, 1, f.state.estimateRemainingLaps(car))
+}
+
+func TestLiveState_ReconstructSession(t *testing.T) {
+	f := newTestLiveStateFixture(t)
+	var sessions []*accresults.Session
+	f.state.sessionEnded = func(session *accresults.Session, since time.Time) {
+		sessions = append(sessions, session)
+	}
+
+	f.logEvents <- logEventTrack{"zandvoort"}
+	<-f.events.Track
+	f.logEvents <- logEventSessionPhaseChanged{"Qualifying", "session"}
+	<-f.events.SessionState
+
+	f.logEvents <- logEventNewConnectionRequest{6, "Driver One", "S76543210987654321", 5}
+	f.logEvents <- logEventNewCarConnection{1002, 5, 42}
+	<-f.events.CarState
+
+	f.logEvents <- logEventNewConnectionRequest{7, "Driver Two", "S5", 6}
+	f.logEvents <- logEventNewCarConnection{1004, 6, 37}
+	<-f.events.CarState
+
+	f.logEvents <- logEventNewLapTime{1002, 0, 123050, 100, 0}
+	assert.NotNil(t, <-f.events.Lap)
+	<-f.events.CarState
+
+	f.logEvents <- logEventNewLapTime{1004, 0, 123040, 101, 0}
+	assert.NotNil(t, <-f.events.Lap)
+	<-f.events.CarState
+	<-f.events.CarState
+	<-f.events.CarState
+
+	f.logEvents <- logEventNewLapTime{1004, 0, 122000, 102, 1}
+	<-f.events.CarState
+
+	f.logEvents <- logEventNewLapTime{1002, 0, 123030, 102, 0}
+	assert.NotNil(t, <-f.events.Lap)
+	<-f.events.CarState
+	<-f.events.CarState
+	<-f.events.CarState
+	assert.Empty(t, sessions)
+
+	f.logEvents <- logEventSessionPhaseChanged{"Race", "session"}
+	<-f.events.SessionState
+	<-f.events.CarState
+	<-f.events.CarState
+
+	if assert.Len(t, sessions, 1) {
+		session := sessions[0]
+		assert.True(t, session.Reconstructed)
+		assert.Equal(t, "zandvoort", session.TrackName)
+		assert.Equal(t, accresults.SessionType(accresults.Qualifying), session.SessionType)
+		assert.Equal(t, 1, session.SessionIndex)
+		assert.Equal(t, 123030, session.SessionResult.BestLap)
+		assert.Len(t, session.Laps, 4)
+		assert.False(t, session.Laps[2].IsValidForBest)
+
+		lines := session.SessionResult.LeaderBoardLines
+		if assert.Len(t, lines, 2) {
+			assert.Equal(t, 1002, lines[0].Car.CarId)
+			assert.Equal(t, 42, lines[0].Car.RaceNumber)
+			assert.Equal(t, 5, lines[0].Car.CarModel)
+			assert.Equal(t, "Driver", lines[0].CurrentDriver.FirstName)
+			assert.Equal(t, "One", lines[0].CurrentDriver.LastName)
+			assert.Equal(t, "S76543210987654321", lines[0].CurrentDriver.PlayerId)
+			assert.Equal(t, 123030, lines[0].Timing.BestLap)
+			assert.Equal(t, 2, lines[0].Timing.LapCount)
+			assert.Equal(t, 246080, lines[0].Timing.TotalTime)
+
+			assert.Equal(t, 1004, lines[1].Car.CarId)
+			assert.Equal(t, 123040, lines[1].Timing.BestLap)
+			assert.Equal(t, 2, lines[1].Timing.LapCount)
+		}
+	}
+
+	// Laps of the previous session are not reported again
+	f.logEvents <- logEventSessionPhaseChanged{"Qualifying", "session"}
+	<-f.events.SessionState
+	<-f.events.CarState
+	<-f.events.CarState
+	assert.Len(t, sessions, 1)
+}
+
+type testLapHistory map[string]int
+
+func (h testLapHistory) BestLap(trackName string, carModel int, playerId string) int {
+	return h[liveBestKey(trackName, carModel, playerId)]
+}
+
+func TestLiveState_LapNotifications(t *testing.T) {
+	f := newTestLiveStateFixture(t)
+	f.state.lapRecords.history = testLapHistory{
+		"zandvoort/-1/S76543210987654321": 96000,
+		"zandvoort/5/S76543210987654321":  98000,
+		"zandvoort/5/":                    95000,
+		"zandvoort/-1/":                   94000,
+	}
+
+	f.logEvents <- logEventTrack{"zandvoort"}
+	<-f.events.Track
+
+	f.logEvents <- logEventNewConnectionRequest{6, "Driver One", "S76543210987654321", 5}
+	f.logEvents <- logEventNewCarConnection{1002, 5, 42}
+	<-f.events.CarState
+	f.logEvents <- logEventNewConnectionRequest{7, "Driver Two", "S5", 6}
+	f.logEvents <- logEventNewCarConnection{1004, 6, 37}
+	<-f.events.CarState
+
+	f.logEvents <- logEventNewLapTime{1002, 0, 99000, 100, 0}
+	lap := <-f.events.Lap
+	assert.Equal(t, 1002, lap.CarID)
+	assert.Equal(t, "Driver One", lap.Driver.Name)
+	assert.Equal(t, 99000, lap.LapTimeMS)
+	assert.Equal(t, []LapRecord{LapRecordPersonalBest, LapRecordSessionBest}, lap.Records)
+	<-f.events.CarState
+
+	f.logEvents <- logEventNewLapTime{1004, 0, 99500, 101, 0}
+	lap = <-f.events.Lap
+	assert.Equal(t, []LapRecord{LapRecordPersonalBest}, lap.Records)
+	<-f.events.CarState
+
+	// Invalid laps never improve any best lap time
+	f.logEvents <- logEventNewLapTime{1002, 0, 90000, 102, flagLapHasCut}
+	<-f.events.CarState
+
+	f.logEvents <- logEventNewLapTime{1002, 0, 97000, 103, 0}
+	lap = <-f.events.Lap
+	assert.Equal(t, []LapRecord{LapRecordPersonalBest, LapRecordSessionBest, LapRecordPlayerTrackCarBest}, lap.Records)
+	assert.True(t, lap.HasRecord(LapRecordPlayerTrackCarBest))
+	assert.False(t, lap.HasRecord(LapRecordPlayerTrackBest))
+	<-f.events.CarState
+
+	f.logEvents <- logEventNewLapTime{1002, 0, 93000, 104, 0}
+	lap = <-f.events.Lap
+	assert.Equal(t, []LapRecord{LapRecordPersonalBest, LapRecordSessionBest, LapRecordPlayerTrackBest,
+		LapRecordPlayerTrackCarBest, LapRecordTrackCarRecord, LapRecordTrackRecord}, lap.Records)
+	<-f.events.CarState
+
+	// Records set live are remembered, even though the history is not updated yet
+	f.logEvents <- logEventNewLapTime{1004, 0, 93500, 105, 0}
+	lap = <-f.events.Lap
+	assert.Equal(t, []LapRecord{LapRecordPersonalBest, LapRecordPlayerTrackBest, LapRecordPlayerTrackCarBest,
+		LapRecordTrackCarRecord}, lap.Records)
+	<-f.events.CarState
+}
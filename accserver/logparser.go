@@ -71,7 +71,9 @@ type logEventCarPurged struct {
 
 // logEventNewLapTime indicates a lap was completed by a car
 type logEventNewLapTime struct {
-	CarID       int
+	CarID int
+	// DriverIndex is the index of the driver within the drivers of the car
+	DriverIndex int
 	LapTimeMS   int
 	TimestampMS int
 	// Flags as binary bitfield with 1=HasCut, 4=IsOutLap, 8=IsInLap (flagLap* constants)
@@ -167,7 +169,7 @@ func makeLogMatchers() (ret []*logMatcher) {
 				if lapTimeMS == 2147483647 { // Constant used for laps not yet completed
 					return nil
 				}
-				return logEventNewLapTime{intOrPanic(matches[1]), lapTimeMS, intOrPanic(matches[6]), intOrPanic(matches[8])}
+				return logEventNewLapTime{intOrPanic(matches[1]), intOrPanic(matches[2]), lapTimeMS, intOrPanic(matches[6]), intOrPanic(matches[8])}
 			}),
 		newLogMatcher(
 			`^\s*Car (\d+) Pos (\d+)$`,
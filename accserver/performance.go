@@ -0,0 +1,247 @@
+package accserver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// performanceSampleInterval is the time between two performance samples
+	performanceSampleInterval = 10 * time.Second
+	// performanceHistorySize is the number of samples kept, which is one hour of history
+	performanceHistorySize = 360
+	// clockTicksPerSecond is the unit of CPU times in /proc, which is 100 on all common Linux systems
+	clockTicksPerSecond = 100
+)
+
+// PerformanceThresholds specifies when to warn about the performance of the accServer process
+type PerformanceThresholds struct {
+	// WarnLateMS is the number of milliseconds the server can run late before warning
+	WarnLateMS int `json:"warnLateMS"`
+	// WarnCPUPercent is the CPU usage before warning, where 100 is one full core
+	WarnCPUPercent float64 `json:"warnCpuPercent"`
+	// WarnMemoryMB is the resident memory usage before warning
+	WarnMemoryMB float64 `json:"warnMemoryMB"`
+}
+
+// withDefaults returns a copy of the thresholds with defaults for all values which are not set
+func (t PerformanceThresholds) withDefaults() PerformanceThresholds {
+	if t.WarnLateMS <= 0 {
+		t.WarnLateMS = 100
+	}
+	if t.WarnCPUPercent <= 0 {
+		t.WarnCPUPercent = 90
+	}
+	if t.WarnMemoryMB <= 0 {
+		t.WarnMemoryMB = 1024
+	}
+	return t
+}
+
+// PerformanceSample contains the performance of the accServer process over a single sample interval
+type PerformanceSample struct {
+	// Time is the end of the sample interval
+	Time time.Time
+	// LateCount is the number of times the server reported it was running late during the interval
+	LateCount int
+	// MaxLateMS is the longest time the server was running late during the interval
+	MaxLateMS int
+	// CPUPercent is the average CPU usage during the interval, where 100 is one full core
+	CPUPercent float64
+	// MemoryMB is the resident memory usage at the end of the interval
+	MemoryMB float64
+}
+
+// PerformanceMonitor keeps a rolling history of the performance of a running accServer process
+//
+// The lag is taken from the "Server was running late" messages in the log. CPU and memory usage are read from /proc,
+// so they are only available on Linux. When accServer runs via wine, wine replaces itself by the actual server
+// process, so the PID is still the one of accServer.
+type PerformanceMonitor struct {
+	mutex      sync.Mutex
+	thresholds PerformanceThresholds
+	samples    []*PerformanceSample
+	// lateCount and maxLateMS are collected for the current interval
+	lateCount int
+	maxLateMS int
+	// lastCPUTicks is the total CPU time used by the process at the previous sample
+	lastCPUTicks uint64
+	// lastSampleTime is the time of the previous sample
+	lastSampleTime time.Time
+	// procAvailable indicates if the process could be found in /proc
+	procAvailable bool
+}
+
+func newPerformanceMonitor(thresholds PerformanceThresholds) *PerformanceMonitor {
+	return &PerformanceMonitor{
+		thresholds: thresholds.withDefaults(),
+		samples:    make([]*PerformanceSample, 0, performanceHistorySize),
+	}
+}
+
+// Thresholds returns the thresholds used for warnings
+func (m *PerformanceMonitor) Thresholds() PerformanceThresholds {
+	return m.thresholds
+}
+
+// ProcAvailable checks if CPU and memory usage of the process are known
+func (m *PerformanceMonitor) ProcAvailable() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.procAvailable
+}
+
+// Samples returns all samples in the history, oldest first
+func (m *PerformanceMonitor) Samples() []*PerformanceSample {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]*PerformanceSample{}, m.samples...)
+}
+
+// Latest returns the most recent sample, or nil if there is none yet
+func (m *PerformanceMonitor) Latest() *PerformanceSample {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if len(m.samples) == 0 {
+		return nil
+	}
+	return m.samples[len(m.samples)-1]
+}
+
+// Warnings returns a description of every threshold exceeded in the most recent sample
+func (m *PerformanceMonitor) Warnings() []string {
+	warnings := make([]string, 0)
+	sample := m.Latest()
+	if sample == nil {
+		return warnings
+	}
+	if sample.MaxLateMS >= m.thresholds.WarnLateMS {
+		warnings = append(warnings, fmt.Sprintf("Server was running late up to %d ms (%d times)", sample.MaxLateMS, sample.LateCount))
+	}
+	if sample.CPUPercent >= m.thresholds.WarnCPUPercent {
+		warnings = append(warnings, fmt.Sprintf("CPU usage is %.0f%%", sample.CPUPercent))
+	}
+	if sample.MemoryMB >= m.thresholds.WarnMemoryMB {
+		warnings = append(warnings, fmt.Sprintf("Memory usage is %.0f MB", sample.MemoryMB))
+	}
+	return warnings
+}
+
+// recordLate records that the server was running late for the given number of milliseconds
+func (m *PerformanceMonitor) recordLate(lateMS int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lateCount++
+	if lateMS > m.maxLateMS {
+		m.maxLateMS = lateMS
+	}
+}
+
+// addSample finishes the current interval at the given time, using the total CPU ticks and resident memory of the
+// process if the process is available in /proc
+func (m *PerformanceMonitor) addSample(now time.Time, procAvailable bool, cpuTicks uint64, memoryKB uint64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	sample := &PerformanceSample{
+		Time:      now,
+		LateCount: m.lateCount,
+		MaxLateMS: m.maxLateMS,
+	}
+	if procAvailable {
+		if m.procAvailable && cpuTicks >= m.lastCPUTicks && now.After(m.lastSampleTime) {
+			cpuSeconds := float64(cpuTicks-m.lastCPUTicks) / clockTicksPerSecond
+			sample.CPUPercent = 100 * cpuSeconds / now.Sub(m.lastSampleTime).Seconds()
+		}
+		sample.MemoryMB = float64(memoryKB) / 1024
+		m.lastCPUTicks = cpuTicks
+	}
+	m.procAvailable = procAvailable
+	m.lastSampleTime = now
+	m.lateCount = 0
+	m.maxLateMS = 0
+
+	if len(m.samples) >= performanceHistorySize {
+		m.samples = append(m.samples[:0], m.samples[1:]...)
+	}
+	m.samples = append(m.samples, sample)
+}
+
+// sample reads the current usage of the process with the given PID and adds a sample for it
+func (m *PerformanceMonitor) sample(pid int) {
+	cpuTicks, errStat := readProcCPUTicks(pid)
+	memoryKB, errStatus := readProcMemoryKB(pid)
+	m.addSample(time.Now(), errStat == nil && errStatus == nil, cpuTicks, memoryKB)
+}
+
+// run samples the process with the given PID at a fixed interval until done is closed
+func (m *PerformanceMonitor) run(pid int, done <-chan bool) {
+	m.sample(pid)
+	ticker := time.NewTicker(performanceSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.sample(pid)
+		case <-done:
+			return
+		}
+	}
+}
+
+// parseProcStat parses the contents of /proc/<pid>/stat into the total CPU ticks used in user and system mode
+func parseProcStat(stat string) (uint64, error) {
+	// The command name is between parentheses and can contain spaces, so start after it
+	end := strings.LastIndex(stat, ")")
+	if end < 0 {
+		return 0, fmt.Errorf("invalid process stat: %s", stat)
+	}
+	// Fields after the command name start at field 3 (state); utime and stime are fields 14 and 15
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("not enough fields in process stat: %s", stat)
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return utime + stime, nil
+}
+
+// parseProcStatus parses the contents of /proc/<pid>/status into the resident memory in kB
+func parseProcStatus(status string) (uint64, error) {
+	for _, line := range strings.Split(status, "\n") {
+		if strings.HasPrefix(line, "VmRSS:") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				break
+			}
+			return strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("no resident memory in process status")
+}
+
+func readProcCPUTicks(pid int) (uint64, error) {
+	contents, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	return parseProcStat(string(contents))
+}
+
+func readProcMemoryKB(pid int) (uint64, error) {
+	contents, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0, err
+	}
+	return parseProcStatus(string(contents))
+}
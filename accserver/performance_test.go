@@ -0,0 +1,86 @@
+package accserver
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPerformance_ParseProcStat(t *testing.T) {
+	ticks, err := parseProcStat("12345 (accServer.exe) S 1 12345 12345 0 -1 4194560 30510 0 0 0 1520 310 0 0 20 0 12 0 1088290 3175424000 40862 18446744073709551615")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1830), ticks)
+
+	ticks, err = parseProcStat("42 (wine (preloader)) R 1 42 42 0 -1 4194560 30510 0 0 0 100 50 0 0 20 0 12 0")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(150), ticks)
+
+	_, err = parseProcStat("42 (short) R 1")
+	assert.NotNil(t, err)
+}
+
+func TestPerformance_ParseProcStatus(t *testing.T) {
+	memory, err := parseProcStatus("Name:\taccServer.exe\nVmPeak:\t 3101000 kB\nVmRSS:\t  163448 kB\nThreads:\t12\n")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(163448), memory)
+
+	_, err = parseProcStatus("Name:\tkthreadd\nThreads:\t1\n")
+	assert.NotNil(t, err)
+}
+
+func TestPerformance_Samples(t *testing.T) {
+	m := newPerformanceMonitor(PerformanceThresholds{WarnLateMS: 500})
+	assert.Equal(t, 500, m.Thresholds().WarnLateMS)
+	assert.Equal(t, 90.0, m.Thresholds().WarnCPUPercent)
+	assert.Nil(t, m.Latest())
+	assert.Empty(t, m.Warnings())
+
+	start := time.Date(2020, 6, 1, 20, 0, 0, 0, time.UTC)
+	m.addSample(start, true, 1000, 102400)
+	assert.Equal(t, &PerformanceSample{start, 0, 0, 0, 100}, m.Latest())
+
+	m.recordLate(120)
+	m.recordLate(600)
+	m.recordLate(80)
+	// 5 seconds of CPU time in 10 seconds
+	m.addSample(start.Add(10*time.Second), true, 1500, 2048*1024)
+	assert.Equal(t, &PerformanceSample{start.Add(10 * time.Second), 3, 600, 50, 2048}, m.Latest())
+	assert.Len(t, m.Warnings(), 2)
+
+	// Lag is collected per interval
+	m.addSample(start.Add(20*time.Second), false, 0, 0)
+	assert.Equal(t, &PerformanceSample{start.Add(20 * time.Second), 0, 0, 0, 0}, m.Latest())
+	assert.False(t, m.ProcAvailable())
+	assert.Empty(t, m.Warnings())
+
+	for i := 0; i < performanceHistorySize; i++ {
+		m.addSample(start.Add(time.Duration(30+10*i)*time.Second), false, 0, 0)
+	}
+	samples := m.Samples()
+	assert.Len(t, samples, performanceHistorySize)
+	assert.Equal(t, start.Add(30*time.Second), samples[0].Time)
+}
+
+func TestPerformance_RunningLateFromLog(t *testing.T) {
+	lates := make([]int, 0)
+	mutex := &sync.Mutex{}
+	sl := &serverLog{
+		mutex:                 mutex,
+		condMessagesAvailable: sync.NewCond(mutex),
+		history:               make([]LogMessage, 0),
+		prefiltering:          true,
+		runningLate:           func(lateMS int) { lates = append(lates, lateMS) },
+	}
+
+	sl.handleLine(LogStreamStdout, "Server was running late: 1043 ms")
+	sl.handleLine(LogStreamStdout, "RegisterToLobby succeeded")
+	assert.Equal(t, []int{1043}, lates)
+	assert.Len(t, sl.history, 1)
+
+	sl.prefiltering = false
+	sl.handleLine(LogStreamStdout, "Server was running late: 64 ms")
+	assert.Equal(t, []int{1043, 64}, lates)
+	assert.Len(t, sl.history, 2)
+}
@@ -0,0 +1,169 @@
+package accserver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/geniusdex/racce/accdata"
+)
+
+// ErrPreflightFailed is returned when starting the server is refused because a pre-flight check failed
+var ErrPreflightFailed = errors.New("pre-flight checks failed")
+
+// PreflightCheck is the result of a single check done before starting accServer
+type PreflightCheck struct {
+	// Name describes what was checked
+	Name string
+	// Problems describes everything that is wrong; it is empty if the check passed
+	Problems []string
+}
+
+// OK checks if the check passed
+func (c *PreflightCheck) OK() bool {
+	return len(c.Problems) == 0
+}
+
+func (c *PreflightCheck) addProblem(format string, args ...interface{}) {
+	c.Problems = append(c.Problems, fmt.Sprintf(format, args...))
+}
+
+// PreflightReport contains the results of all checks done before starting accServer
+type PreflightReport struct {
+	// Time is when the checks were done
+	Time time.Time
+	// Checks contains all checks in the order they were done
+	Checks []*PreflightCheck
+}
+
+// OK checks if all checks passed
+func (r *PreflightReport) OK() bool {
+	for _, check := range r.Checks {
+		if !check.OK() {
+			return false
+		}
+	}
+	return true
+}
+
+// Error returns an error describing all problems, or nil if all checks passed
+func (r *PreflightReport) Error() error {
+	problems := make([]string, 0)
+	for _, check := range r.Checks {
+		problems = append(problems, check.Problems...)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrPreflightFailed, strings.Join(problems, "; "))
+}
+
+func (r *PreflightReport) newCheck(name string) *PreflightCheck {
+	check := &PreflightCheck{Name: name, Problems: make([]string, 0)}
+	r.Checks = append(r.Checks, check)
+	return check
+}
+
+// checkExecutable checks if accServer.exe and the wrapper to launch it are available
+func checkExecutable(check *PreflightCheck, config *Configuration) {
+	if info, err := os.Stat(config.executable()); err != nil {
+		check.addProblem("Cannot find accServer.exe: %v", err)
+	} else if info.IsDir() {
+		check.addProblem("'%s' is a directory", config.executable())
+	}
+
+	if wrapper := config.exeWrapper(); wrapper != "" {
+		if _, err := exec.LookPath(wrapper); err != nil {
+			check.addProblem("Cannot find executable wrapper '%s': %v", wrapper, err)
+		}
+	} else if runtime.GOOS != "windows" {
+		check.addProblem("No executable wrapper configured and wine is not installed")
+	}
+}
+
+// validatePort checks if a port number from the configuration is valid
+func validatePort(check *PreflightCheck, name string, port int) {
+	if !isValidPort(port) {
+		check.addProblem("Invalid %s port %d", name, port)
+	}
+}
+
+// validateCfg checks the accServer configuration for values which prevent the server from running properly
+func validateCfg(check *PreflightCheck, cfg *ServerConfiguration) {
+	validatePort(check, "TCP", cfg.Configuration.TCPPort)
+	validatePort(check, "UDP", cfg.Configuration.UDPPort)
+	if cfg.Configuration.MaxConnections <= 0 {
+		check.addProblem("Maximum number of connections must be positive")
+	}
+
+	if cfg.Settings.MaxCarSlots <= 0 {
+		check.addProblem("Maximum number of car slots must be positive")
+	}
+
+	if accdata.TrackByLabel(cfg.Event.Track) == nil {
+		check.addProblem("Unknown track '%s'", cfg.Event.Track)
+	}
+	if len(cfg.Event.Sessions) == 0 {
+		check.addProblem("The event has no sessions")
+	}
+	for i, session := range cfg.Event.Sessions {
+		if _, ok := liveSessionTypes[session.SessionType]; !ok {
+			check.addProblem("Session %d has unknown session type '%s'", i+1, session.SessionType)
+		}
+		if session.SessionDurationMinutes <= 0 {
+			check.addProblem("Session %d has no duration", i+1)
+		}
+	}
+}
+
+// isValidPort checks if a port number can be used at all
+func isValidPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+// checkPortsAvailable checks if the TCP and UDP ports can be bound; invalid port numbers are skipped
+func checkPortsAvailable(check *PreflightCheck, cfg *CfgConfiguration) {
+	if isValidPort(cfg.TCPPort) {
+		if listener, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.TCPPort)); err != nil {
+			check.addProblem("TCP port %d is not available: %v", cfg.TCPPort, err)
+		} else {
+			listener.Close()
+		}
+	}
+
+	if isValidPort(cfg.UDPPort) {
+		if conn, err := net.ListenPacket("udp", fmt.Sprintf(":%d", cfg.UDPPort)); err != nil {
+			check.addProblem("UDP port %d is not available: %v", cfg.UDPPort, err)
+		} else {
+			conn.Close()
+		}
+	}
+}
+
+// Preflight checks if everything is in place to start the server. The cfg files are parsed again from disk, because
+// that is what accServer will use.
+func (s *Server) Preflight() *PreflightReport {
+	report := &PreflightReport{
+		Time:   time.Now(),
+		Checks: make([]*PreflightCheck, 0),
+	}
+
+	checkExecutable(report.newCheck("Executable"), s.Config)
+
+	cfgCheck := report.newCheck("Configuration files")
+	cfg, err := parseCfg(s.Config.installationDir())
+	if err != nil {
+		cfgCheck.addProblem("%v", err)
+		return report
+	}
+	validateCfg(cfgCheck, cfg)
+
+	checkPortsAvailable(report.newCheck("Ports"), cfg.Configuration)
+
+	return report
+}
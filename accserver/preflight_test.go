@@ -0,0 +1,144 @@
+package accserver
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// freePort returns a port which is likely to be available for both TCP and UDP
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// newTestPreflightServer creates a server with a complete installation directory which passes all checks
+func newTestPreflightServer(t *testing.T) *Server {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no shell available to use as executable wrapper")
+	}
+
+	dir, err := ioutil.TempDir("", "accserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err := os.Mkdir(filepath.Join(dir, "cfg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "accServer.exe"), []byte("exit 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	port := freePort(t)
+	cfg := &ServerConfiguration{
+		&CfgConfiguration{UDPPort: port, TCPPort: port, MaxConnections: 10},
+		&CfgSettings{ServerName: "Test", MaxCarSlots: 10},
+		newTestEventCfg(),
+		nil,
+		nil,
+	}
+	server := &Server{Config: &Configuration{InstallationDir: dir, ExeWrapper: sh}, Cfg: cfg}
+	saveTestCfg(t, server)
+	return server
+}
+
+func saveTestCfg(t *testing.T, server *Server) {
+	cfgDir := filepath.Join(server.Config.InstallationDir, "cfg")
+	for name, value := range map[string]interface{}{
+		"configuration.json": server.Cfg.Configuration,
+		"settings.json":      server.Cfg.Settings,
+		"event.json":         server.Cfg.Event,
+	} {
+		if err := writeCfgFile(filepath.Join(cfgDir, name), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPreflight_OK(t *testing.T) {
+	server := newTestPreflightServer(t)
+
+	report := server.Preflight()
+	assert.True(t, report.OK())
+	assert.Nil(t, report.Error())
+	assert.Len(t, report.Checks, 3)
+}
+
+func TestPreflight_Executable(t *testing.T) {
+	server := newTestPreflightServer(t)
+	server.Config.ExeWrapper = "/nonexistent/wine"
+	os.Remove(server.Config.executable())
+
+	report := server.Preflight()
+	assert.False(t, report.OK())
+	assert.Len(t, report.Checks[0].Problems, 2)
+	assert.True(t, errors.Is(report.Error(), ErrPreflightFailed))
+}
+
+func TestPreflight_CfgUnparseable(t *testing.T) {
+	server := newTestPreflightServer(t)
+	path := filepath.Join(server.Config.InstallationDir, "cfg", "event.json")
+	if err := ioutil.WriteFile(path, []byte("{\"track\": "), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report := server.Preflight()
+	assert.False(t, report.OK())
+	assert.Len(t, report.Checks, 2)
+	assert.Len(t, report.Checks[1].Problems, 1)
+}
+
+func TestPreflight_CfgInvalid(t *testing.T) {
+	server := newTestPreflightServer(t)
+	server.Cfg.Configuration.TCPPort = 70000
+	server.Cfg.Event.Track = "nordschleife"
+	server.Cfg.Event.Sessions[1].SessionDurationMinutes = 0
+	saveTestCfg(t, server)
+
+	report := server.Preflight()
+	assert.False(t, report.OK())
+	assert.Equal(t, []string{"Invalid TCP port 70000", "Unknown track 'nordschleife'", "Session 2 has no duration"}, report.Checks[1].Problems)
+	assert.True(t, report.Checks[2].OK())
+}
+
+func TestPreflight_PortsInUse(t *testing.T) {
+	server := newTestPreflightServer(t)
+	port := server.Cfg.Configuration.TCPPort
+
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	conn, err := net.ListenPacket("udp", ":"+strconv.Itoa(port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	report := server.Preflight()
+	assert.False(t, report.OK())
+	assert.Len(t, report.Checks[2].Problems, 2)
+}
+
+func TestPreflight_StartRefused(t *testing.T) {
+	server := newTestPreflightServer(t)
+	os.Remove(server.Config.executable())
+
+	assert.True(t, errors.Is(server.Start(), ErrPreflightFailed))
+	assert.Nil(t, server.Instance)
+	assert.False(t, server.LastPreflight.OK())
+}
@@ -0,0 +1,144 @@
+package accserver
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/geniusdex/racce/accdata"
+)
+
+// QuickSession describes a one-off server run which does not change the saved configuration
+type QuickSession struct {
+	Track    string
+	Sessions []*CfgEventSession
+	Password string
+}
+
+// validate checks if the quick session can be run
+func (q *QuickSession) validate() error {
+	if accdata.TrackByLabel(q.Track) == nil {
+		return fmt.Errorf("unknown track '%s'", q.Track)
+	}
+	if len(q.Sessions) == 0 {
+		return fmt.Errorf("quick session has no sessions")
+	}
+	return nil
+}
+
+// cfg returns the configuration for the quick session, based on the saved configuration
+func (q *QuickSession) cfg(saved *ServerConfiguration) *ServerConfiguration {
+	settings := *saved.Settings
+	settings.Password = q.Password
+
+	event := *saved.Event
+	event.Track = q.Track
+	event.Sessions = make([]*CfgEventSession, len(q.Sessions))
+	for i, session := range q.Sessions {
+		copied := *session
+		event.Sessions[i] = &copied
+	}
+
+	return &ServerConfiguration{
+		saved.Configuration,
+		&settings,
+		&event,
+		saved.EventRules,
+		saved.EntryList,
+	}
+}
+
+// recoverSavedCfg restores the saved configuration if racce stopped during a quick session. It returns the restored
+// configuration, or nil if there was nothing to restore.
+func recoverSavedCfg(config *Configuration) (*ServerConfiguration, error) {
+	if _, err := os.Stat(config.savedCfgDir()); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	log.Printf("Restoring configuration saved before quick session")
+	cfg, err := parseCfgDir(config.savedCfgDir())
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse configuration saved before quick session: %v", err)
+	}
+	if cfg.EntryList != nil {
+		// The entry list is only saved aside when racce changed it, so it has to be restored
+		cfg.EntryList.modified = true
+	}
+	if err := writeCfgDir(config.cfgDir(), cfg); err != nil {
+		return nil, fmt.Errorf("Cannot restore configuration saved before quick session: %v", err)
+	}
+	if err := os.RemoveAll(config.savedCfgDir()); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// StartQuickSession launches an instance of the server for a quick session. The saved configuration is kept aside
+// and restored automatically when the instance stops.
+func (s *Server) StartQuickSession(quick *QuickSession) error {
+	if s.Instance.State() != Stopped {
+		return fmt.Errorf("server is already running")
+	}
+	if err := quick.validate(); err != nil {
+		return err
+	}
+
+	cfg, err := s.beginQuickSession(quick)
+	if err != nil {
+		return err
+	}
+	if err := s.start(cfg.Event); err != nil {
+		s.endQuickSession()
+		return err
+	}
+
+	instance := s.Instance
+	go func() {
+		<-instance.exited
+		s.endQuickSession()
+	}()
+	return nil
+}
+
+// QuickSession returns the quick session being run, or nil if the saved configuration is used
+func (s *Server) QuickSession() *QuickSession {
+	s.quickSessionMutex.Lock()
+	defer s.quickSessionMutex.Unlock()
+	return s.quickSession
+}
+
+// beginQuickSession keeps the saved configuration aside and writes the configuration for the quick session
+func (s *Server) beginQuickSession(quick *QuickSession) (*ServerConfiguration, error) {
+	s.quickSessionMutex.Lock()
+	defer s.quickSessionMutex.Unlock()
+	if err := writeCfgDir(s.Config.savedCfgDir(), s.Cfg); err != nil {
+		return nil, err
+	}
+	cfg := quick.cfg(s.Cfg)
+	if err := writeCfgDir(s.Config.cfgDir(), cfg); err != nil {
+		s.restoreSavedCfg()
+		return nil, err
+	}
+	s.quickSession = quick
+	return cfg, nil
+}
+
+// endQuickSession forgets the quick session and restores the saved configuration
+func (s *Server) endQuickSession() {
+	s.quickSessionMutex.Lock()
+	defer s.quickSessionMutex.Unlock()
+	s.quickSession = nil
+	s.restoreSavedCfg()
+}
+
+// restoreSavedCfg writes the saved configuration to disk again after a quick session; the caller must hold
+// quickSessionMutex
+func (s *Server) restoreSavedCfg() {
+	if err := writeCfgDir(s.Config.cfgDir(), s.Cfg); err != nil {
+		log.Printf("Cannot restore configuration after quick session: %v", err)
+		return
+	}
+	if err := os.RemoveAll(s.Config.savedCfgDir()); err != nil {
+		log.Printf("Cannot remove configuration saved before quick session: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package accserver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestQuickSession() *QuickSession {
+	return &QuickSession{
+		Track:    "monza",
+		Sessions: []*CfgEventSession{{HourOfDay: 14, DayOfWeekend: 2, TimeMultiplier: 1, SessionType: Practice, SessionDurationMinutes: 20}},
+		Password: "secret",
+	}
+}
+
+func TestQuickSession_Validate(t *testing.T) {
+	assert.Nil(t, newTestQuickSession().validate())
+
+	quick := newTestQuickSession()
+	quick.Track = "nordschleife"
+	assert.NotNil(t, quick.validate())
+
+	quick = newTestQuickSession()
+	quick.Sessions = nil
+	assert.NotNil(t, quick.validate())
+}
+
+func TestServer_StartQuickSession(t *testing.T) {
+	server := newTestPreflightServer(t)
+	server.LiveState = newLiveState()
+	script := filepath.Join(server.Config.InstallationDir, "accServer.exe")
+	if err := ioutil.WriteFile(script, []byte("exec sleep 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, server.StartQuickSession(newTestQuickSession()))
+	assert.True(t, server.IsRunning())
+	assert.NotNil(t, server.QuickSession())
+
+	// The quick session is on disk for accServer, while the saved configuration is unchanged
+	cfg, err := parseCfg(server.Config.installationDir())
+	assert.Nil(t, err)
+	assert.Equal(t, "monza", cfg.Event.Track)
+	assert.Equal(t, "secret", cfg.Settings.Password)
+	assert.Equal(t, "zandvoort", server.Cfg.Event.Track)
+	assert.Equal(t, "", server.Cfg.Settings.Password)
+
+	// Saving during the quick session does not overwrite the quick session on disk
+	server.Cfg.Event.AmbientTemp = 30
+	assert.Nil(t, server.SaveConfiguration())
+	cfg, err = parseCfg(server.Config.installationDir())
+	assert.Nil(t, err)
+	assert.Equal(t, "monza", cfg.Event.Track)
+
+	instance := server.Instance
+	assert.Nil(t, server.Stop())
+	<-instance.exited
+	for i := 0; i < 100 && server.QuickSession() != nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(server.Config.savedCfgDir()); os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Nil(t, server.QuickSession())
+	cfg, err = parseCfg(server.Config.installationDir())
+	assert.Nil(t, err)
+	assert.Equal(t, "zandvoort", cfg.Event.Track)
+	assert.Equal(t, 30, cfg.Event.AmbientTemp)
+	assert.Equal(t, "", cfg.Settings.Password)
+	_, err = os.Stat(server.Config.savedCfgDir())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestServer_StartQuickSessionRefused(t *testing.T) {
+	server := newTestPreflightServer(t)
+	server.LiveState = newLiveState()
+	server.Config.ExeWrapper = filepath.Join(server.Config.InstallationDir, "missing")
+
+	assert.NotNil(t, server.StartQuickSession(newTestQuickSession()))
+	assert.Nil(t, server.QuickSession())
+	cfg, err := parseCfg(server.Config.installationDir())
+	assert.Nil(t, err)
+	assert.Equal(t, "zandvoort", cfg.Event.Track)
+	_, err = os.Stat(server.Config.savedCfgDir())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRecoverSavedCfg(t *testing.T) {
+	server := newTestPreflightServer(t)
+
+	cfg, err := recoverSavedCfg(server.Config)
+	assert.Nil(t, err)
+	assert.Nil(t, cfg)
+
+	// Simulate racce stopping during a quick session
+	assert.Nil(t, writeCfgDir(server.Config.savedCfgDir(), server.Cfg))
+	assert.Nil(t, writeCfgDir(server.Config.cfgDir(), newTestQuickSession().cfg(server.Cfg)))
+
+	cfg, err = recoverSavedCfg(server.Config)
+	assert.Nil(t, err)
+	assert.Equal(t, "zandvoort", cfg.Event.Track)
+	onDisk, err := parseCfg(server.Config.installationDir())
+	assert.Nil(t, err)
+	assert.Equal(t, cfg, onDisk)
+	_, err = os.Stat(server.Config.savedCfgDir())
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,277 @@
+package accserver
+
+import (
+	"io/ioutil"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/geniusdex/racce/accresults"
+)
+
+const (
+	// resultsFileWait is the time to wait after a session ended before checking if accServer wrote a results file
+	resultsFileWait = 30 * time.Second
+)
+
+var (
+	// resultsSessionTypes maps the session types used in the log to the types used in results files
+	resultsSessionTypes = map[string]accresults.SessionType{
+		SessionTypePractice:   accresults.Practice,
+		SessionTypeQualifying: accresults.Qualifying,
+		SessionTypeRace:       accresults.Race,
+	}
+)
+
+// recordedLap is a lap completed during the current session, kept to be able to reconstruct the results
+type recordedLap struct {
+	carID       int
+	driver      *Driver
+	lapTimeMS   int
+	timestampMS int
+	flags       int
+}
+
+// isValid checks if the lap counts for the best lap time
+func (lap *recordedLap) isValid() bool {
+	return lap.flags&flagLapHasCut == 0
+}
+
+// reconstructedCar collects the results of a single car while reconstructing a session
+type reconstructedCar struct {
+	state     *CarState
+	line      *accresults.LeaderBoardLine
+	lastLapMS int
+}
+
+// splitName splits a full player name from the log into first and last name
+func splitName(name string) (string, string) {
+	parts := strings.SplitN(strings.TrimSpace(name), " ", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// shortName derives a three letter short name from a last name
+func shortName(lastName string) string {
+	short := strings.ToUpper(strings.ReplaceAll(lastName, " ", ""))
+	if len(short) > 3 {
+		return short[:3]
+	}
+	return short
+}
+
+func newResultsDriver(driver *Driver) *accresults.Driver {
+	firstName, lastName := splitName(driver.Name)
+	return &accresults.Driver{
+		FirstName: firstName,
+		LastName:  lastName,
+		ShortName: shortName(lastName),
+		PlayerId:  driver.PlayerID,
+	}
+}
+
+// driverIndex returns the index of a driver within the drivers of a car in this session, or -1 if not found
+func (car *CarState) driverIndex(driver *Driver) int {
+	if driver == nil {
+		return -1
+	}
+	for i, d := range car.sessionDrivers {
+		if d.PlayerID == driver.PlayerID {
+			return i
+		}
+	}
+	return -1
+}
+
+// addSessionDriver adds a driver to the drivers of a car in this session, unless the player is already known
+func (car *CarState) addSessionDriver(driver *Driver) {
+	if car.driverIndex(driver) < 0 {
+		car.sessionDrivers = append(car.sessionDrivers, driver)
+	}
+}
+
+func newReconstructedCar(car *CarState) *reconstructedCar {
+	resultsCar := &accresults.Car{
+		CarId:      car.CarID,
+		RaceNumber: car.RaceNumber,
+		Drivers:    make([]*accresults.Driver, len(car.sessionDrivers)),
+	}
+	if car.CarModel != nil {
+		resultsCar.CarModel = car.CarModel.ID
+	}
+	for i, driver := range car.sessionDrivers {
+		resultsCar.Drivers[i] = newResultsDriver(driver)
+	}
+
+	currentDriverIndex := car.driverIndex(car.CurrentDriver)
+	if currentDriverIndex < 0 {
+		currentDriverIndex = 0
+	}
+
+	return &reconstructedCar{
+		state: car,
+		line: &accresults.LeaderBoardLine{
+			Car:                resultsCar,
+			CurrentDriver:      resultsCar.Drivers[currentDriverIndex],
+			CurrentDriverIndex: currentDriverIndex,
+			Timing: &accresults.LeaderBoardTiming{
+				BestLap: math.MaxInt32,
+			},
+			DriverTotalTimes: make([]float64, len(resultsCar.Drivers)),
+		},
+	}
+}
+
+// addLap adds a recorded lap to the results of the car and returns the matching lap for the results
+func (rc *reconstructedCar) addLap(lap *recordedLap) *accresults.Lap {
+	driverIndex := rc.state.driverIndex(lap.driver)
+	if driverIndex < 0 {
+		driverIndex = rc.line.CurrentDriverIndex
+	}
+
+	timing := rc.line.Timing
+	timing.LapCount++
+	timing.LastLap = lap.lapTimeMS
+	timing.TotalTime += lap.lapTimeMS
+	if lap.isValid() && lap.lapTimeMS < timing.BestLap {
+		timing.BestLap = lap.lapTimeMS
+	}
+	rc.line.DriverTotalTimes[driverIndex] += float64(lap.lapTimeMS)
+	rc.lastLapMS = lap.timestampMS
+
+	return &accresults.Lap{
+		CarId:          lap.carID,
+		DriverIndex:    driverIndex,
+		Laptime:        lap.lapTimeMS,
+		IsValidForBest: lap.isValid(),
+	}
+}
+
+// sortReconstructedCars sorts cars on most distance for races, and on fastest lap for other sessions
+func sortReconstructedCars(cars []*reconstructedCar, sessionType string) {
+	sort.SliceStable(cars, func(i, j int) bool {
+		a, b := cars[i], cars[j]
+		if sessionType == SessionTypeRace {
+			if a.line.Timing.LapCount != b.line.Timing.LapCount {
+				return a.line.Timing.LapCount > b.line.Timing.LapCount
+			}
+			if a.lastLapMS != b.lastLapMS {
+				return a.lastLapMS < b.lastLapMS
+			}
+		} else if a.line.Timing.BestLap != b.line.Timing.BestLap {
+			return a.line.Timing.BestLap < b.line.Timing.BestLap
+		}
+		return a.state.Position < b.state.Position
+	})
+}
+
+// reconstructSession builds session results from the laps recorded during the session with the given state. It
+// returns nil if there is nothing to reconstruct.
+func (ls *LiveState) reconstructSession(state *SessionState) *accresults.Session {
+	if len(ls.sessionLaps) == 0 {
+		return nil
+	}
+
+	sessionIndex := state.Index
+	if sessionIndex < 0 {
+		sessionIndex = 0
+	}
+
+	session := &accresults.Session{
+		TrackName:     ls.Track.Label,
+		SessionType:   resultsSessionTypes[state.Type],
+		SessionIndex:  sessionIndex,
+		SessionResult: &accresults.SessionResult{BestLap: math.MaxInt32},
+		Laps:          make([]*accresults.Lap, 0, len(ls.sessionLaps)),
+		Penalties:     make([]*accresults.Penalty, 0),
+		Reconstructed: true,
+	}
+	if ls.eventCfg != nil {
+		session.MetaData = ls.eventCfg.MetaData
+	}
+
+	cars := make(map[int]*reconstructedCar)
+	for _, lap := range ls.sessionLaps {
+		car := cars[lap.carID]
+		if car == nil {
+			carState := ls.CarState[lap.carID]
+			if carState == nil || len(carState.sessionDrivers) == 0 {
+				continue
+			}
+			car = newReconstructedCar(carState)
+			cars[lap.carID] = car
+		}
+		session.Laps = append(session.Laps, car.addLap(lap))
+		if lap.isValid() && lap.lapTimeMS < session.SessionResult.BestLap {
+			session.SessionResult.BestLap = lap.lapTimeMS
+		}
+	}
+
+	sortedCars := make([]*reconstructedCar, 0, len(cars))
+	for _, car := range cars {
+		sortedCars = append(sortedCars, car)
+	}
+	sortReconstructedCars(sortedCars, state.Type)
+	for _, car := range sortedCars {
+		session.SessionResult.LeaderBoardLines = append(session.SessionResult.LeaderBoardLines, car.line)
+	}
+
+	return session
+}
+
+// endSession finishes recording the session with the given state, reports the reconstructed results and starts
+// recording a new session
+func (ls *LiveState) endSession(state *SessionState) {
+	if session := ls.reconstructSession(state); session != nil && ls.sessionEnded != nil {
+		ls.sessionEnded(session, ls.sessionRecordingStart)
+	}
+
+	ls.sessionLaps = make([]*recordedLap, 0)
+	ls.sessionRecordingStart = ls.now()
+	for _, car := range ls.CarState {
+		car.sessionDrivers = append([]*Driver{}, car.Drivers...)
+	}
+}
+
+// hasResultsFileSince checks if accServer wrote a results file for a session type since the given time
+func (s *Server) hasResultsFileSince(sessionType accresults.SessionType, since time.Time) bool {
+	files, err := ioutil.ReadDir(s.Config.ResolveResultsDir())
+	if err != nil {
+		log.Printf("Cannot read results directory: %v", err)
+		return false
+	}
+
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), "_"+string(sessionType)+".json") && !f.ModTime().Before(since) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeReconstructedResults writes reconstructed session results to the results directory like accServer does
+func (s *Server) writeReconstructedResults(session *accresults.Session) error {
+	session.ServerName = s.Cfg.Settings.ServerName
+	fileName := time.Now().Format("060102_150405") + "_" + string(session.SessionType) + ".json"
+	path := strings.TrimRight(s.Config.ResolveResultsDir(), "/") + "/" + fileName
+	log.Printf("Writing reconstructed session results to '%s'", path)
+	return writeCfgFile(path, session)
+}
+
+// handleSessionEnded writes reconstructed results for a session if accServer does not write a results file itself
+// for a session which started recording at the given time
+func (s *Server) handleSessionEnded(session *accresults.Session, since time.Time) {
+	go func() {
+		time.Sleep(resultsFileWait)
+		if s.hasResultsFileSince(session.SessionType, since) {
+			return
+		}
+		if err := s.writeReconstructedResults(session); err != nil {
+			log.Printf("Cannot write reconstructed session results: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,274 @@
+package accserver
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/geniusdex/racce/accdata"
+)
+
+const (
+	// RotateOnWeekendReset rotates the track whenever accServer resets the race weekend
+	RotateOnWeekendReset = "weekendReset"
+	// RotateWhenEmpty rotates the track whenever the last client leaves the server
+	RotateWhenEmpty = "empty"
+	// RotateOnSchedule rotates the track at fixed times of the day
+	RotateOnSchedule = "schedule"
+
+	// rotationScheduleFormat is the format of the times of day in the rotation schedule
+	rotationScheduleFormat = "15:04"
+	// rotationScheduleInterval is the time between two checks of the rotation schedule
+	rotationScheduleInterval = 30 * time.Second
+)
+
+// RotationTrack is a single track in the rotation
+type RotationTrack struct {
+	// Track is the label of the track, as used in event.json
+	Track string `json:"track"`
+	// Sessions replaces the sessions of the event on this track; the current sessions are kept if it is empty
+	Sessions []*CfgEventSession `json:"sessions"`
+}
+
+// TrackRotationConfiguration specifies how the server rotates between tracks
+type TrackRotationConfiguration struct {
+	// Tracks contains the tracks in the rotation
+	Tracks []*RotationTrack `json:"tracks"`
+	// Shuffle visits the tracks in random order instead of the configured order
+	Shuffle bool `json:"shuffle"`
+	// Trigger is one of "weekendReset", "empty" or "schedule"
+	Trigger string `json:"trigger"`
+	// Schedule contains the times of day at which to rotate for the "schedule" trigger, formatted as HH:MM
+	Schedule []string `json:"schedule"`
+}
+
+// validate checks if the configuration can be used for a rotation
+func (c *TrackRotationConfiguration) validate() error {
+	if len(c.Tracks) == 0 {
+		return fmt.Errorf("track rotation contains no tracks")
+	}
+	for _, track := range c.Tracks {
+		if accdata.TrackByLabel(track.Track) == nil {
+			return fmt.Errorf("unknown track '%s' in track rotation", track.Track)
+		}
+	}
+	switch c.Trigger {
+	case RotateOnWeekendReset, RotateWhenEmpty:
+	case RotateOnSchedule:
+		if len(c.Schedule) == 0 {
+			return fmt.Errorf("track rotation on schedule without any times")
+		}
+		for _, at := range c.Schedule {
+			if _, err := time.Parse(rotationScheduleFormat, at); err != nil {
+				return fmt.Errorf("invalid time '%s' in track rotation schedule: %w", at, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown track rotation trigger '%s'", c.Trigger)
+	}
+	return nil
+}
+
+// scheduleDue checks if any of the times of day in the schedule lies in the interval (last, now]
+func scheduleDue(schedule []string, last time.Time, now time.Time) bool {
+	for _, at := range schedule {
+		timeOfDay, err := time.Parse(rotationScheduleFormat, at)
+		if err != nil {
+			continue
+		}
+		// Check both today and yesterday, in case the interval crosses midnight
+		for days := 0; days >= -1; days-- {
+			day := now.AddDate(0, 0, days)
+			due := time.Date(day.Year(), day.Month(), day.Day(), timeOfDay.Hour(), timeOfDay.Minute(), 0, 0, now.Location())
+			if due.After(last) && !due.After(now) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TrackRotation keeps track of the position in the rotation of tracks
+type TrackRotation struct {
+	mutex  sync.Mutex
+	config *TrackRotationConfiguration
+	random *rand.Rand
+	// order contains the indices of the tracks in the order they are visited
+	order []int
+	// position is the position in order of the current track, or -1 if the current track is not in the rotation
+	position int
+	// rotating indicates a rotation is in progress
+	rotating bool
+}
+
+// newTrackRotation creates a rotation which continues after the given current track
+func newTrackRotation(config *TrackRotationConfiguration, currentTrack string, random *rand.Rand) (*TrackRotation, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	r := &TrackRotation{
+		config:   config,
+		random:   random,
+		order:    make([]int, len(config.Tracks)),
+		position: -1,
+	}
+	for i := range r.order {
+		r.order[i] = i
+	}
+	if config.Shuffle {
+		r.random.Shuffle(len(r.order), func(i, j int) { r.order[i], r.order[j] = r.order[j], r.order[i] })
+	}
+	for position, index := range r.order {
+		if config.Tracks[index].Track == currentTrack {
+			r.position = position
+			break
+		}
+	}
+	return r, nil
+}
+
+// Trigger returns the configured trigger for rotating
+func (r *TrackRotation) Trigger() string {
+	return r.config.Trigger
+}
+
+// Upcoming returns the track that will be used at the next rotation
+func (r *TrackRotation) Upcoming() *RotationTrack {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.position+1 < len(r.order) {
+		return r.config.Tracks[r.order[r.position+1]]
+	}
+	// The order is shuffled again at the end, but it never starts with the current track
+	if r.config.Shuffle {
+		return nil
+	}
+	return r.config.Tracks[r.order[0]]
+}
+
+// advance moves to the next track in the rotation and returns it
+func (r *TrackRotation) advance() *RotationTrack {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.position++
+	if r.position >= len(r.order) {
+		if r.config.Shuffle && len(r.order) > 1 {
+			last := r.order[len(r.order)-1]
+			r.random.Shuffle(len(r.order), func(i, j int) { r.order[i], r.order[j] = r.order[j], r.order[i] })
+			if r.order[0] == last {
+				r.order[0], r.order[len(r.order)-1] = r.order[len(r.order)-1], r.order[0]
+			}
+		}
+		r.position = 0
+	}
+	return r.config.Tracks[r.order[r.position]]
+}
+
+// apply changes the event configuration to use the given track and its sessions
+func (t *RotationTrack) apply(event *CfgEvent) {
+	event.Track = t.Track
+	if len(t.Sessions) > 0 {
+		event.Sessions = make([]*CfgEventSession, len(t.Sessions))
+		for i, session := range t.Sessions {
+			copied := *session
+			event.Sessions[i] = &copied
+		}
+	}
+}
+
+// startRotating marks the start of a rotation; it returns false if a rotation is already in progress
+func (r *TrackRotation) startRotating() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.rotating {
+		return false
+	}
+	r.rotating = true
+	return true
+}
+
+func (r *TrackRotation) stopRotating() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.rotating = false
+}
+
+// EnableTrackRotation rotates the track of the event according to the given configuration.
+//
+// This must be called before the first instance of the server is started.
+func (s *Server) EnableTrackRotation(config *TrackRotationConfiguration) error {
+	rotation, err := newTrackRotation(config, s.Cfg.Event.Track, rand.New(rand.NewSource(time.Now().UnixNano())))
+	if err != nil {
+		return err
+	}
+	s.Rotation = rotation
+
+	switch config.Trigger {
+	case RotateOnWeekendReset:
+		s.LiveState.weekendReset = s.triggerRotation
+	case RotateWhenEmpty:
+		s.LiveState.serverEmptied = s.triggerRotation
+	case RotateOnSchedule:
+		go s.runRotationSchedule()
+	}
+	return nil
+}
+
+// triggerRotation rotates the track in the background, as stopping the server waits for the live state to finish
+func (s *Server) triggerRotation() {
+	go s.RotateTrack()
+}
+
+// runRotationSchedule rotates the track whenever a time in the schedule has passed while the server is running
+func (s *Server) runRotationSchedule() {
+	last := time.Now()
+	ticker := time.NewTicker(rotationScheduleInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if scheduleDue(s.Rotation.config.Schedule, last, now) && s.IsRunning() {
+			s.RotateTrack()
+		}
+		last = now
+	}
+}
+
+// RotateTrack changes the event to the next track in the rotation. If the server is running, it is restarted to
+// use the new track.
+func (s *Server) RotateTrack() {
+	if s.QuickSession() != nil {
+		log.Printf("Not rotating track during a quick session")
+		return
+	}
+	if !s.Rotation.startRotating() {
+		return
+	}
+	defer s.Rotation.stopRotating()
+
+	instance := s.Instance
+	wasRunning := instance.State() == Running
+	if wasRunning {
+		if err := s.Stop(); err != nil {
+			log.Printf("Cannot stop server to rotate track: %v", err)
+			return
+		}
+		<-instance.exited
+	}
+
+	track := s.Rotation.advance()
+	log.Printf("Rotating to track '%s'", track.Track)
+	track.apply(s.Cfg.Event)
+	if err := s.SaveConfiguration(); err != nil {
+		log.Printf("Cannot save configuration after rotating track: %v", err)
+		return
+	}
+
+	if wasRunning {
+		if err := s.Start(); err != nil {
+			log.Printf("Cannot restart server after rotating track: %v", err)
+		}
+	}
+}
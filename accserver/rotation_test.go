@@ -0,0 +1,151 @@
+package accserver
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRotationConfiguration(tracks ...string) *TrackRotationConfiguration {
+	config := &TrackRotationConfiguration{Trigger: RotateOnWeekendReset}
+	for _, track := range tracks {
+		config.Tracks = append(config.Tracks, &RotationTrack{Track: track})
+	}
+	return config
+}
+
+func TestTrackRotationConfiguration_Validate(t *testing.T) {
+	assert.Nil(t, newTestRotationConfiguration("monza", "spa").validate())
+	assert.NotNil(t, newTestRotationConfiguration().validate())
+	assert.NotNil(t, newTestRotationConfiguration("monza", "nordschleife").validate())
+
+	config := newTestRotationConfiguration("monza")
+	config.Trigger = "sometimes"
+	assert.NotNil(t, config.validate())
+
+	config.Trigger = RotateOnSchedule
+	assert.NotNil(t, config.validate())
+	config.Schedule = []string{"20:00", "8pm"}
+	assert.NotNil(t, config.validate())
+	config.Schedule = []string{"20:00", "08:00"}
+	assert.Nil(t, config.validate())
+}
+
+func TestScheduleDue(t *testing.T) {
+	schedule := []string{"20:00", "00:00"}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2020, 6, 1, hour, minute, 0, 0, time.UTC)
+	}
+	assert.False(t, scheduleDue(schedule, at(19, 59), at(19, 59).Add(30*time.Second)))
+	assert.True(t, scheduleDue(schedule, at(19, 59).Add(30*time.Second), at(20, 0)))
+	assert.False(t, scheduleDue(schedule, at(20, 0), at(20, 0).Add(30*time.Second)))
+	assert.True(t, scheduleDue(schedule, at(23, 59).Add(30*time.Second), at(24, 0).Add(15*time.Second)))
+}
+
+func TestTrackRotation_Ordered(t *testing.T) {
+	rotation, err := newTrackRotation(newTestRotationConfiguration("monza", "spa", "zolder"), "spa", rand.New(rand.NewSource(1)))
+	assert.Nil(t, err)
+
+	assert.Equal(t, "zolder", rotation.Upcoming().Track)
+	assert.Equal(t, "zolder", rotation.advance().Track)
+	assert.Equal(t, "monza", rotation.Upcoming().Track)
+	assert.Equal(t, "monza", rotation.advance().Track)
+	assert.Equal(t, "spa", rotation.advance().Track)
+}
+
+func TestTrackRotation_CurrentTrackNotInRotation(t *testing.T) {
+	rotation, err := newTrackRotation(newTestRotationConfiguration("monza", "spa"), "zandvoort", rand.New(rand.NewSource(1)))
+	assert.Nil(t, err)
+	assert.Equal(t, "monza", rotation.advance().Track)
+}
+
+func TestTrackRotation_Shuffled(t *testing.T) {
+	config := newTestRotationConfiguration("monza", "spa", "zolder", "misano")
+	config.Shuffle = true
+	rotation, err := newTrackRotation(config, "", rand.New(rand.NewSource(1)))
+	assert.Nil(t, err)
+
+	previous := ""
+	for round := 0; round < 10; round++ {
+		visited := make(map[string]bool)
+		for i := 0; i < len(config.Tracks); i++ {
+			track := rotation.advance().Track
+			assert.NotEqual(t, previous, track)
+			visited[track] = true
+			previous = track
+		}
+		assert.Len(t, visited, len(config.Tracks))
+	}
+}
+
+func TestRotationTrack_Apply(t *testing.T) {
+	event := newTestEventCfg()
+	sessions := event.Sessions
+
+	(&RotationTrack{Track: "monza"}).apply(event)
+	assert.Equal(t, "monza", event.Track)
+	assert.Equal(t, sessions, event.Sessions)
+
+	track := &RotationTrack{Track: "spa", Sessions: []*CfgEventSession{{SessionType: Race, SessionDurationMinutes: 60}}}
+	track.apply(event)
+	assert.Equal(t, "spa", event.Track)
+	assert.Equal(t, track.Sessions, event.Sessions)
+	event.Sessions[0].SessionDurationMinutes = 30
+	assert.Equal(t, 60, track.Sessions[0].SessionDurationMinutes)
+}
+
+func TestServer_RotateTrack(t *testing.T) {
+	server := newTestPreflightServer(t)
+	server.LiveState = newLiveState()
+	assert.Nil(t, server.EnableTrackRotation(newTestRotationConfiguration("monza", "zandvoort", "spa")))
+	script := filepath.Join(server.Config.InstallationDir, "accServer.exe")
+	if err := ioutil.WriteFile(script, []byte("exec sleep 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A stopped server only gets its configuration updated
+	server.RotateTrack()
+	assert.Equal(t, "spa", server.Cfg.Event.Track)
+	assert.True(t, server.IsStopped())
+	cfg, err := parseCfg(server.Config.installationDir())
+	assert.Nil(t, err)
+	assert.Equal(t, "spa", cfg.Event.Track)
+
+	// A running server is restarted with the new track
+	assert.Nil(t, server.Start())
+	first := server.Instance
+	server.RotateTrack()
+	assert.Equal(t, "monza", server.Cfg.Event.Track)
+	assert.True(t, first.IsStopped())
+	assert.True(t, server.IsRunning())
+
+	assert.Nil(t, server.Stop())
+	<-server.Instance.exited
+}
+
+func TestLiveState_RotationTriggers(t *testing.T) {
+	f := newTestLiveStateFixture(t)
+	triggers := make(chan string, 2)
+	f.state.weekendReset = func() { triggers <- RotateOnWeekendReset }
+	f.state.serverEmptied = func() { triggers <- RotateWhenEmpty }
+
+	f.logEvents <- logEventNrClientsOnline{2}
+	assert.Equal(t, 2, <-f.events.NrClients)
+	f.logEvents <- logEventNrClientsOnline{0}
+	assert.Equal(t, 0, <-f.events.NrClients)
+	assert.Equal(t, RotateWhenEmpty, <-triggers)
+
+	f.logEvents <- logEventNrClientsOnline{0}
+	assert.Equal(t, 0, <-f.events.NrClients)
+
+	f.logEvents <- logEventResettingWeekend{}
+	<-f.events.SessionState
+	f.logEvents <- logEventNrClientsOnline{1}
+	assert.Equal(t, 1, <-f.events.NrClients)
+	assert.Equal(t, RotateOnWeekendReset, <-triggers)
+	assert.Empty(t, triggers)
+}
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
+	"sync"
 
 	"golang.org/x/text/encoding/unicode"
 )
@@ -32,6 +34,9 @@ const (
 	GT4                 = "GT4"
 	GTC                 = "GTC"
 	TCX                 = "TCX"
+
+	// maxPastRuns is the number of summaries of previous instances which are kept
+	maxPastRuns = 10
 )
 
 // CfgConfiguration contains the main server connectivity configuration.
@@ -69,6 +74,58 @@ type CfgEvent struct {
 	ConfigVersion             int                `json:"configVersion"`
 }
 
+// CfgEventRules contains the rules for pit stops and stints during the event.
+type CfgEventRules struct {
+	QualifyStandingType                  int  `json:"qualifyStandingType"`
+	PitWindowLengthSec                   int  `json:"pitWindowLengthSec"`
+	DriverStintTimeSec                   int  `json:"driverStintTimeSec"`
+	MandatoryPitstopCount                int  `json:"mandatoryPitstopCount"`
+	MaxTotalDrivingTime                  int  `json:"maxTotalDrivingTime"`
+	MaxDriversCount                      int  `json:"maxDriversCount"`
+	IsRefuellingAllowedInRace            bool `json:"isRefuellingAllowedInRace"`
+	IsRefuellingTimeFixed                bool `json:"isRefuellingTimeFixed"`
+	IsMandatoryPitstopRefuellingRequired bool `json:"isMandatoryPitstopRefuellingRequired"`
+	IsMandatoryPitstopTyreChangeRequired bool `json:"isMandatoryPitstopTyreChangeRequired"`
+	IsMandatoryPitstopSwapDriverRequired bool `json:"isMandatoryPitstopSwapDriverRequired"`
+	TyreSetCount                         int  `json:"tyreSetCount"`
+	ConfigVersion                        int  `json:"configVersion"`
+}
+
+// CfgEntryListDriver contains a single driver of an entry in the entry list.
+type CfgEntryListDriver struct {
+	FirstName      string `json:"firstName"`
+	LastName       string `json:"lastName"`
+	ShortName      string `json:"shortName"`
+	Nationality    int    `json:"nationality"`
+	DriverCategory int    `json:"driverCategory"`
+	PlayerID       string `json:"playerID"`
+}
+
+// CfgEntryListEntry contains a single car in the entry list.
+type CfgEntryListEntry struct {
+	Drivers                      []*CfgEntryListDriver `json:"drivers"`
+	RaceNumber                   int                   `json:"raceNumber"`
+	ForcedCarModel               *int                  `json:"forcedCarModel"`
+	OverrideDriverInfo           int                   `json:"overrideDriverInfo"`
+	IsServerAdmin                int                   `json:"isServerAdmin"`
+	CustomCar                    string                `json:"customCar"`
+	OverrideCarModelForCustomCar int                   `json:"overrideCarModelForCustomCar"`
+	BallastKg                    int                   `json:"ballastKg"`
+	Restrictor                   int                   `json:"restrictor"`
+	DefaultGridPosition          int                   `json:"defaultGridPosition"`
+}
+
+// CfgEntryList contains the cars and drivers known to the server.
+type CfgEntryList struct {
+	Entries        []*CfgEntryListEntry `json:"entries"`
+	ForceEntryList int                  `json:"forceEntryList"`
+	ConfigVersion  int                  `json:"configVersion"`
+
+	// modified is set when racce changed the entry list; an unchanged entry list is never written, so fields which
+	// racce does not know are kept
+	modified bool
+}
+
 // CfgSettings contains generic server settings.
 type CfgSettings struct {
 	ServerName                 string   `json:"serverName"`
@@ -99,6 +156,10 @@ type ServerConfiguration struct {
 	Configuration *CfgConfiguration
 	Settings      *CfgSettings
 	Event         *CfgEvent
+	// EventRules is nil if the installation has no eventRules.json
+	EventRules *CfgEventRules
+	// EntryList is nil if the installation has no entrylist.json
+	EntryList *CfgEntryList
 }
 
 // Server represents an accServer installation, providing access to its
@@ -112,6 +173,20 @@ type Server struct {
 	Instance *Instance
 	// LiveState contains the live state monitoring of the accServer; always available, even before first start
 	LiveState *LiveState
+	// Hotlaps contains the hotlap leaderboards, or nil if they are not enabled
+	Hotlaps *HotlapBoard
+	// LastPreflight contains the results of the most recent pre-flight checks, or nil if none were done yet
+	LastPreflight *PreflightReport
+	// Rotation contains the automatic track rotation, or nil if it is not enabled
+	Rotation *TrackRotation
+	// WeatherHistory contains the chosen weather setups, or nil if they are not recorded
+	WeatherHistory *WeatherHistory
+	// quickSession contains the quick session being run, or nil if the saved configuration is used
+	quickSession *QuickSession
+	// quickSessionMutex guards quickSession and the configuration files while a quick session starts or ends
+	quickSessionMutex sync.Mutex
+	// pastRuns contains the summaries of the previous instances, oldest first
+	pastRuns []RunSummary
 }
 
 func isUtf16(data []byte) bool {
@@ -151,65 +226,186 @@ func writeCfgFile(path string, source interface{}) error {
 }
 
 func parseCfg(installationPath string) (*ServerConfiguration, error) {
+	return parseCfgDir(installationPath + "/cfg/")
+}
+
+// parseCfgDir parses the cfg files in the given directory, which must end with a slash
+func parseCfgDir(cfgDir string) (*ServerConfiguration, error) {
 	cfg := &ServerConfiguration{
 		&CfgConfiguration{},
 		&CfgSettings{},
 		&CfgEvent{},
+		&CfgEventRules{},
+		&CfgEntryList{},
 	}
 
-	if err := parseCfgFile(installationPath+"/cfg/configuration.json", cfg.Configuration); err != nil {
-		return nil, fmt.Errorf("Cannot parse cfg/configuration.json: %v", err)
+	if err := parseCfgFile(cfgDir+"configuration.json", cfg.Configuration); err != nil {
+		return nil, fmt.Errorf("Cannot parse %sconfiguration.json: %v", cfgDir, err)
 	}
 
-	if err := parseCfgFile(installationPath+"/cfg/settings.json", cfg.Settings); err != nil {
-		return nil, fmt.Errorf("Cannot parse cfg/settings.json: %v", err)
+	if err := parseCfgFile(cfgDir+"settings.json", cfg.Settings); err != nil {
+		return nil, fmt.Errorf("Cannot parse %ssettings.json: %v", cfgDir, err)
 	}
 
-	if err := parseCfgFile(installationPath+"/cfg/event.json", cfg.Event); err != nil {
-		return nil, fmt.Errorf("Cannot parse cfg/event.json: %v", err)
+	if err := parseCfgFile(cfgDir+"event.json", cfg.Event); err != nil {
+		return nil, fmt.Errorf("Cannot parse %sevent.json: %v", cfgDir, err)
+	}
+
+	// The server can be managed without the optional files, so they are left out when they cannot be parsed
+	if err := parseCfgFile(cfgDir+"eventRules.json", cfg.EventRules); err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Ignoring %seventRules.json which cannot be parsed: %v", cfgDir, err)
+		}
+		cfg.EventRules = nil
+	}
+
+	if err := parseCfgFile(cfgDir+"entrylist.json", cfg.EntryList); err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Ignoring %sentrylist.json which cannot be parsed: %v", cfgDir, err)
+		}
+		cfg.EntryList = nil
 	}
 
 	return cfg, nil
 }
 
+// writeCfgDir writes the cfg files into the given directory, which must end with a slash
+func writeCfgDir(cfgDir string, cfg *ServerConfiguration) error {
+	if err := os.MkdirAll(cfgDir, 0755); err != nil {
+		return err
+	}
+
+	if err := writeCfgFile(cfgDir+"configuration.json", cfg.Configuration); err != nil {
+		return fmt.Errorf("Cannot write %sconfiguration.json: %w", cfgDir, err)
+	}
+
+	if err := writeCfgFile(cfgDir+"settings.json", cfg.Settings); err != nil {
+		return fmt.Errorf("Cannot write %ssettings.json: %w", cfgDir, err)
+	}
+
+	if err := writeCfgFile(cfgDir+"event.json", cfg.Event); err != nil {
+		return fmt.Errorf("Cannot write %sevent.json: %w", cfgDir, err)
+	}
+
+	if cfg.EventRules != nil {
+		if err := writeCfgFile(cfgDir+"eventRules.json", cfg.EventRules); err != nil {
+			return fmt.Errorf("Cannot write %seventRules.json: %w", cfgDir, err)
+		}
+	}
+
+	if cfg.EntryList != nil && cfg.EntryList.modified {
+		if err := writeCfgFile(cfgDir+"entrylist.json", cfg.EntryList); err != nil {
+			return fmt.Errorf("Cannot write %sentrylist.json: %w", cfgDir, err)
+		}
+	}
+
+	return nil
+}
+
 // NewServer creates a new Server based on the given configuration.
 func NewServer(config *Configuration) (*Server, error) {
 	if _, err := os.Stat(config.executable()); err != nil {
 		return nil, fmt.Errorf("Could not locate accServer.exe: %v", err)
 	}
 
-	cfg, err := parseCfg(config.installationDir())
+	cfg, err := recoverSavedCfg(config)
 	if err != nil {
-		return nil, fmt.Errorf("Cannot parse server config: %v", err)
+		return nil, err
+	}
+	if cfg == nil {
+		cfg, err = parseCfg(config.installationDir())
+		if err != nil {
+			return nil, fmt.Errorf("Cannot parse server config: %v", err)
+		}
 	}
 
-	return &Server{
+	s := &Server{
 		config,
 		cfg,
 		nil,
 		newLiveState(),
-	}, nil
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		sync.Mutex{},
+		make([]RunSummary, 0),
+	}
+	s.LiveState.sessionEnded = s.handleSessionEnded
+
+	return s, nil
 }
 
-// Start launches an instance of the server
+// SetLapHistory sets the source of historical lap times used to detect new records in the live state.
+//
+// This must be called before the first instance of the server is started.
+func (s *Server) SetLapHistory(history LapHistory) {
+	s.LiveState.lapRecords.history = history
+}
+
+// EnableHotlaps records all valid live laps into hotlap leaderboards stored in the given file, using the windows
+// from the configuration.
+//
+// This must be called before the first instance of the server is started.
+func (s *Server) EnableHotlaps(path string) error {
+	board, err := LoadHotlapBoard(path, s.Config.Hotlaps)
+	if err != nil {
+		return err
+	}
+	s.Hotlaps = board
+	s.LiveState.hotlaps = board
+	return nil
+}
+
+// Start launches an instance of the server, if all pre-flight checks pass
 func (s *Server) Start() error {
+	return s.start(s.Cfg.Event)
+}
+
+// start launches an instance of the server with the event configuration that was written to disk
+func (s *Server) start(event *CfgEvent) error {
 	if s.Instance.State() != Stopped {
 		return fmt.Errorf("server is already running")
 	}
 
+	s.LastPreflight = s.Preflight()
+	if err := s.LastPreflight.Error(); err != nil {
+		return err
+	}
+
 	instance, err := newInstance(s.Config)
 	if err != nil {
 		return err
 	}
 
+	if s.Instance != nil {
+		s.pastRuns = append(s.pastRuns, s.Instance.Summary())
+		if len(s.pastRuns) > maxPastRuns {
+			s.pastRuns = s.pastRuns[len(s.pastRuns)-maxPastRuns:]
+		}
+	}
 	s.Instance = instance
+	s.LiveState.serverVersionDetected = instance.setVersion
 
 	logParser := newLogParser(instance.NewLogChannel())
-	s.LiveState.newInstance(logParser.Events)
+	s.LiveState.newInstance(logParser.Events, event)
 
 	return nil
 }
 
+// Runs returns the summaries of the current and previous instances, most recent first
+func (s *Server) Runs() []RunSummary {
+	runs := make([]RunSummary, 0, len(s.pastRuns)+1)
+	if s.Instance != nil {
+		runs = append(runs, s.Instance.Summary())
+	}
+	for i := len(s.pastRuns) - 1; i >= 0; i-- {
+		runs = append(runs, s.pastRuns[i])
+	}
+	return runs
+}
+
 // Stop stops a running instance of the server
 func (s *Server) Stop() error {
 	return s.Instance.stop()
@@ -231,20 +427,13 @@ func (s *Server) IsStopping() bool {
 }
 
 // SaveConfiguration saves the current in-memory configuration to disk
+//
+// During a quick session, the configuration is only saved to disk after the quick session has ended.
 func (s *Server) SaveConfiguration() error {
-	cfgDir := s.Config.installationDir() + "/cfg/"
-
-	if err := writeCfgFile(cfgDir+"configuration.json", s.Cfg.Configuration); err != nil {
-		return fmt.Errorf("Cannot write cfg/configuration.json: %w", err)
-	}
-
-	if err := writeCfgFile(cfgDir+"settings.json", s.Cfg.Settings); err != nil {
-		return fmt.Errorf("Cannot write cfg/settings.json: %w", err)
+	s.quickSessionMutex.Lock()
+	defer s.quickSessionMutex.Unlock()
+	if s.quickSession != nil {
+		return writeCfgDir(s.Config.savedCfgDir(), s.Cfg)
 	}
-
-	if err := writeCfgFile(cfgDir+"event.json", s.Cfg.Event); err != nil {
-		return fmt.Errorf("Cannot write cfg/event.json: %w", err)
-	}
-
-	return nil
+	return writeCfgDir(s.Config.cfgDir(), s.Cfg)
 }
@@ -2,19 +2,34 @@ package accserver
 
 import (
 	"bufio"
+	"io"
 	"log"
 	"os/exec"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
+// LogStream identifies the output stream of the process on which a log message was written
+type LogStream string
+
+const (
+	// LogStreamStdout is the standard output, on which accServer writes its log
+	LogStreamStdout LogStream = "stdout"
+	// LogStreamStderr is the standard error, on which mostly wine and crash output is written
+	LogStreamStderr LogStream = "stderr"
+)
+
 // LogMessage is a single message from the server log
 type LogMessage struct {
 	// Message is the actual message itself
 	Message string
 	// Time is the time at which the log message was received
 	Time time.Time
+	// Stream is the output stream on which the message was written
+	Stream LogStream
 }
 
 // serverLog contains the entire server log since startup and allows reading it
@@ -23,42 +38,55 @@ type serverLog struct {
 	mutex *sync.Mutex
 	// condMessagesAvailable is broadcasted whenever new messages are available
 	condMessagesAvailable *sync.Cond
-	// scanner scans the log output for new lines
-	scanner *bufio.Scanner
 	// history contains all past log messages
 	history []LogMessage
 	// isDone indicates if the process has quit
 	isDone bool
-	// doneChannel is closed whenever the stdout pipe of the process is closed
+	// doneChannel is closed whenever both the stdout and stderr pipes of the process are closed
 	doneChannel chan bool
 	// prefiltering is true if log messages have to be filtered before being stored
 	prefiltering bool
+	// runningLate is called with the number of milliseconds whenever the server reports it was running late
+	runningLate func(lateMS int)
 }
 
 const (
 	initialHistoryCapacity = 1024
 )
 
+var (
+	// runningLateMatcher matches the message in which the server reports it was running late
+	runningLateMatcher = regexp.MustCompile(`^Server was running late\D*(\d+)`)
+)
+
 // newServerLog constructs a new serverLog object for a not-yet running process
-func newServerLog(cmd *exec.Cmd, prefiltering bool) (*serverLog, error) {
+func newServerLog(cmd *exec.Cmd, prefiltering bool, runningLate func(lateMS int)) (*serverLog, error) {
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return nil, err
 	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
 
 	mutex := &sync.Mutex{}
 
 	sl := &serverLog{
 		mutex:                 mutex,
 		condMessagesAvailable: sync.NewCond(mutex),
-		scanner:               bufio.NewScanner(stdout),
 		history:               make([]LogMessage, 0, initialHistoryCapacity),
 		isDone:                false,
 		doneChannel:           make(chan bool),
 		prefiltering:          prefiltering,
+		runningLate:           runningLate,
 	}
 
-	go sl.monitor()
+	monitors := &sync.WaitGroup{}
+	monitors.Add(2)
+	go sl.monitor(LogStreamStdout, stdout, monitors)
+	go sl.monitor(LogStreamStderr, stderr, monitors)
+	go sl.waitForMonitors(monitors)
 
 	return sl, nil
 }
@@ -77,27 +105,41 @@ func (sl *serverLog) Wait() {
 	<-sl.doneChannel
 }
 
-// monitor watches the server log for new messages and handles them
-func (sl *serverLog) monitor() {
-	for sl.scanner.Scan() {
-		sl.handleLine(strings.TrimSpace(sl.scanner.Text()))
+// monitor watches a single output stream of the process for new messages and handles them
+func (sl *serverLog) monitor(stream LogStream, reader io.Reader, monitors *sync.WaitGroup) {
+	defer monitors.Done()
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		sl.handleLine(stream, strings.TrimSpace(scanner.Text()))
 	}
-	if err := sl.scanner.Err(); err != nil {
-		log.Printf("Error while reading server console: %v", err)
+	if err := scanner.Err(); err != nil {
+		log.Printf("Error while reading server console %s: %v", stream, err)
 	}
+}
+
+// waitForMonitors marks the log as done after all output streams of the process have been closed
+func (sl *serverLog) waitForMonitors(monitors *sync.WaitGroup) {
+	monitors.Wait()
+	sl.mutex.Lock()
 	sl.isDone = true
+	sl.mutex.Unlock()
 	sl.condMessagesAvailable.Broadcast()
 	close(sl.doneChannel)
-	// "the source-monitor error, participants might misattribute"
 }
 
 // handleLine handles a new log line coming in
-func (sl *serverLog) handleLine(line string) {
-	if sl.prefiltering && strings.HasPrefix(line, "Server was running late") {
-		return
+func (sl *serverLog) handleLine(stream LogStream, line string) {
+	// Running late messages are handled here, because they are needed for performance monitoring even when filtered
+	if matches := runningLateMatcher.FindStringSubmatch(line); matches != nil {
+		if lateMS, err := strconv.Atoi(matches[1]); err == nil && sl.runningLate != nil {
+			sl.runningLate(lateMS)
+		}
+		if sl.prefiltering {
+			return
+		}
 	}
 
-	msg := LogMessage{line, time.Now()}
+	msg := LogMessage{line, time.Now(), stream}
 
 	sl.mutex.Lock()
 
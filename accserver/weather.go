@@ -0,0 +1,237 @@
+package accserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/geniusdex/racce/accdata"
+)
+
+const (
+	// WeatherRandom is the name used for weather generated by the random generator
+	WeatherRandom = "random"
+
+	// maxWeatherRecords is the number of generated weather setups kept in the history
+	maxWeatherRecords = 100
+)
+
+// Weather contains the weather settings of an event
+type Weather struct {
+	AmbientTemp       int     `json:"ambientTemp"`
+	CloudLevel        float32 `json:"cloudLevel"`
+	Rain              float32 `json:"rain"`
+	WeatherRandomness int     `json:"weatherRandomness"`
+}
+
+// ApplyTo changes the weather settings of the event
+func (w *Weather) ApplyTo(event *CfgEvent) {
+	event.AmbientTemp = w.AmbientTemp
+	event.CloudLevel = w.CloudLevel
+	event.Rain = w.Rain
+	event.WeatherRandomness = w.WeatherRandomness
+}
+
+// WeatherPreset is a named weather setup
+type WeatherPreset struct {
+	Name    string
+	Weather Weather
+}
+
+var (
+	// WeatherPresets contains the weather setups selectable by name
+	WeatherPresets = []*WeatherPreset{
+		{"Dry summer", Weather{AmbientTemp: 26, CloudLevel: 0.1, Rain: 0, WeatherRandomness: 1}},
+		{"Changeable", Weather{AmbientTemp: 18, CloudLevel: 0.5, Rain: 0.2, WeatherRandomness: 4}},
+		{"Wet", Weather{AmbientTemp: 14, CloudLevel: 0.8, Rain: 0.6, WeatherRandomness: 3}},
+	}
+)
+
+// WeatherPresetByName returns the preset with the given name, or nil if there is none
+func WeatherPresetByName(name string) *WeatherPreset {
+	for _, preset := range WeatherPresets {
+		if preset.Name == name {
+			return preset
+		}
+	}
+	return nil
+}
+
+// trackClimate describes the typical weather on a track during the racing season
+type trackClimate struct {
+	minTemp int
+	maxTemp int
+	// wetChance is the chance of a wet weekend
+	wetChance float64
+}
+
+var (
+	// defaultClimate is used for tracks without a known climate
+	defaultClimate = trackClimate{14, 26, 0.3}
+
+	// trackClimates contains the climate per track label
+	trackClimates = map[string]trackClimate{
+		"monza":          {18, 30, 0.2},
+		"zolder":         {12, 24, 0.4},
+		"brands_hatch":   {12, 24, 0.4},
+		"silverstone":    {12, 24, 0.4},
+		"paul_ricard":    {20, 32, 0.1},
+		"misano":         {20, 32, 0.15},
+		"spa":            {10, 24, 0.5},
+		"nurburgring":    {10, 24, 0.45},
+		"barcelona":      {20, 32, 0.1},
+		"hungaroring":    {20, 34, 0.15},
+		"zandvoort":      {12, 22, 0.4},
+		"imola":          {16, 30, 0.25},
+		"valencia":       {20, 32, 0.1},
+		"kyalami":        {16, 30, 0.2},
+		"mount_panorama": {10, 26, 0.25},
+		"suzuka":         {16, 30, 0.3},
+		"laguna_seca":    {14, 26, 0.1},
+		"oulton_park":    {10, 22, 0.45},
+		"donington":      {10, 22, 0.45},
+		"snetterton":     {10, 22, 0.4},
+		"cota":           {20, 34, 0.15},
+		"indianapolis":   {16, 30, 0.25},
+		"watkins_glen":   {14, 28, 0.3},
+	}
+)
+
+// roundTenth rounds a value to a single decimal, matching the steps of the weather settings
+func roundTenth(value float64) float32 {
+	return float32(math.Round(value*10) / 10)
+}
+
+// climateForTrack returns the climate of a track, which may be given by any of its labels
+func climateForTrack(track string) trackClimate {
+	if t := accdata.TrackByLabel(track); t != nil {
+		track = t.Label
+	}
+	if climate, ok := trackClimates[track]; ok {
+		return climate
+	}
+	return defaultClimate
+}
+
+// GenerateWeather picks a realistic weather setup for the track, which is always the same for the same seed
+func GenerateWeather(track string, seed int64) Weather {
+	climate := climateForTrack(track)
+	random := rand.New(rand.NewSource(seed))
+
+	weather := Weather{
+		AmbientTemp: climate.minTemp + random.Intn(climate.maxTemp-climate.minTemp+1),
+	}
+	if random.Float64() < climate.wetChance {
+		// Wet weekends are cooler, but never colder than the climate allows
+		weather.AmbientTemp -= random.Intn(4)
+		if weather.AmbientTemp < climate.minTemp {
+			weather.AmbientTemp = climate.minTemp
+		}
+		weather.CloudLevel = roundTenth(0.6 + 0.4*random.Float64())
+		weather.Rain = roundTenth(0.1 + 0.5*random.Float64())
+		weather.WeatherRandomness = 2 + random.Intn(4)
+	} else {
+		weather.CloudLevel = roundTenth(0.5 * random.Float64())
+		weather.WeatherRandomness = random.Intn(4)
+	}
+	return weather
+}
+
+// WeatherRecord describes a weather setup chosen from a preset or generated for an event
+type WeatherRecord struct {
+	Time  time.Time `json:"time"`
+	Track string    `json:"track"`
+	// Source is the name of the preset, or "random" for generated weather
+	Source string `json:"source"`
+	// Seed is the seed of the random generator; it is only used for generated weather
+	Seed    int64   `json:"seed"`
+	Weather Weather `json:"weather"`
+}
+
+// IsRandom checks if the weather was generated randomly
+func (r *WeatherRecord) IsRandom() bool {
+	return r.Source == WeatherRandom
+}
+
+// WeatherHistory keeps the most recent chosen weather setups, stored durably on disk
+type WeatherHistory struct {
+	mutex   sync.RWMutex
+	path    string
+	records []*WeatherRecord
+}
+
+// LoadWeatherHistory loads the weather history stored in the given file, or creates an empty history if the file does
+// not exist
+func LoadWeatherHistory(path string) (*WeatherHistory, error) {
+	history := &WeatherHistory{
+		path:    path,
+		records: make([]*WeatherRecord, 0),
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return history, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(contents, &history.records); err != nil {
+		return nil, fmt.Errorf("cannot parse weather history from '%s': %w", path, err)
+	}
+	return history, nil
+}
+
+// Record adds a weather setup to the history and saves it
+func (h *WeatherHistory) Record(record *WeatherRecord) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.records = append(h.records, record)
+	if len(h.records) > maxWeatherRecords {
+		h.records = h.records[len(h.records)-maxWeatherRecords:]
+	}
+
+	contents, err := json.Marshal(h.records)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(h.path, contents, 0644)
+}
+
+// Records returns all weather setups in the history, most recent first
+func (h *WeatherHistory) Records() []*WeatherRecord {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	records := make([]*WeatherRecord, len(h.records))
+	for i, record := range h.records {
+		records[len(records)-1-i] = record
+	}
+	return records
+}
+
+// Latest returns the most recent weather setup, or nil if the history is empty
+func (h *WeatherHistory) Latest() *WeatherRecord {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if len(h.records) == 0 {
+		return nil
+	}
+	return h.records[len(h.records)-1]
+}
+
+// EnableWeatherHistory records all chosen weather setups in the given file
+//
+// This must be called before the admin pages are used.
+func (s *Server) EnableWeatherHistory(path string) error {
+	history, err := LoadWeatherHistory(path)
+	if err != nil {
+		return err
+	}
+	s.WeatherHistory = history
+	return nil
+}
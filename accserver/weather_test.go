@@ -0,0 +1,85 @@
+package accserver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateWeather_Reproducible(t *testing.T) {
+	assert.Equal(t, GenerateWeather("spa", 1234), GenerateWeather("spa", 1234))
+
+	different := false
+	for seed := int64(0); seed < 10; seed++ {
+		if GenerateWeather("spa", seed) != GenerateWeather("spa", seed+1) {
+			different = true
+		}
+	}
+	assert.True(t, different)
+}
+
+func TestGenerateWeather_WithinClimate(t *testing.T) {
+	for _, track := range []string{"spa", "barcelona", "unknown"} {
+		climate := climateForTrack(track)
+		for seed := int64(0); seed < 100; seed++ {
+			weather := GenerateWeather(track, seed)
+			assert.GreaterOrEqual(t, weather.AmbientTemp, climate.minTemp)
+			assert.LessOrEqual(t, weather.AmbientTemp, climate.maxTemp)
+			assert.GreaterOrEqual(t, weather.CloudLevel, float32(0))
+			assert.LessOrEqual(t, weather.CloudLevel, float32(1))
+			assert.GreaterOrEqual(t, weather.Rain, float32(0))
+			assert.LessOrEqual(t, weather.Rain, float32(1))
+			assert.GreaterOrEqual(t, weather.WeatherRandomness, 0)
+			assert.LessOrEqual(t, weather.WeatherRandomness, 7)
+			if weather.Rain > 0 {
+				assert.GreaterOrEqual(t, weather.CloudLevel, float32(0.6))
+			}
+		}
+	}
+}
+
+func TestGenerateWeather_AlternateTrackLabel(t *testing.T) {
+	assert.Equal(t, trackClimates["monza"], climateForTrack("monza_2020"))
+	assert.Equal(t, defaultClimate, climateForTrack("unknown"))
+	for seed := int64(0); seed < 10; seed++ {
+		assert.Equal(t, GenerateWeather("monza", seed), GenerateWeather("monza_2020", seed))
+	}
+}
+
+func TestWeatherPresetByName(t *testing.T) {
+	assert.Equal(t, WeatherPresets[2], WeatherPresetByName("Wet"))
+	assert.Nil(t, WeatherPresetByName("Snow"))
+
+	event := newTestEventCfg()
+	WeatherPresetByName("Wet").Weather.ApplyTo(event)
+	assert.Equal(t, 14, event.AmbientTemp)
+	assert.Equal(t, float32(0.6), event.Rain)
+}
+
+func TestWeatherHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "weather")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "weather.json")
+
+	history, err := LoadWeatherHistory(path)
+	assert.Nil(t, err)
+	assert.Nil(t, history.Latest())
+
+	first := &WeatherRecord{Time: time.Date(2020, 6, 1, 20, 0, 0, 0, time.UTC), Track: "spa", Source: WeatherRandom, Seed: 42, Weather: GenerateWeather("spa", 42)}
+	second := &WeatherRecord{Time: time.Date(2020, 6, 2, 20, 0, 0, 0, time.UTC), Track: "monza", Source: "Wet", Weather: WeatherPresets[2].Weather}
+	assert.Nil(t, history.Record(first))
+	assert.Nil(t, history.Record(second))
+	assert.Equal(t, second, history.Latest())
+
+	reloaded, err := LoadWeatherHistory(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []*WeatherRecord{second, first}, reloaded.Records())
+	assert.True(t, reloaded.Records()[1].IsRandom())
+}
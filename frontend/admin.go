@@ -1,10 +1,12 @@
 package frontend
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strings"
 
+	"github.com/geniusdex/racce/accdata"
 	"github.com/geniusdex/racce/accserver"
 	"github.com/gorilla/securecookie"
 	"github.com/gorilla/sessions"
@@ -16,6 +18,7 @@ type admin struct {
 	serveMux *http.ServeMux
 	server   *accserver.Server
 	frontend *frontend
+	uploads  *uploadStore
 }
 
 func newAdmin(config *Configuration, accServer *accserver.Server, frontend *frontend) *admin {
@@ -25,6 +28,7 @@ func newAdmin(config *Configuration, accServer *accserver.Server, frontend *fron
 		http.NewServeMux(),
 		accServer,
 		frontend,
+		newUploadStore(),
 	}
 
 	admin.serveMux.HandleFunc("/admin", admin.indexHandler)
@@ -32,10 +36,19 @@ func newAdmin(config *Configuration, accServer *accserver.Server, frontend *fron
 	admin.serveMux.HandleFunc("/admin/server", admin.serverHandler)
 	admin.serveMux.HandleFunc("/admin/server/start", admin.serverStartHandler)
 	admin.serveMux.HandleFunc("/admin/server/stop", admin.serverStopHandler)
+	admin.serveMux.HandleFunc("/admin/server/preflight", admin.serverPreflightHandler)
+	admin.serveMux.HandleFunc("/admin/server/rotate", admin.serverRotateHandler)
+	admin.serveMux.HandleFunc("/admin/server/quick", admin.serverQuickHandler)
+	admin.serveMux.HandleFunc("/admin/server/grid", admin.serverGridHandler)
+	admin.serveMux.HandleFunc("/admin/server/ballast", admin.serverBallastHandler)
 	admin.serveMux.HandleFunc("/admin/server/cfg/global", admin.cfgGlobalHandler)
 	admin.serveMux.HandleFunc("/admin/server/cfg/event", admin.cfgEventHandler)
+	admin.serveMux.HandleFunc("/admin/server/cfg/wizard", admin.cfgWizardHandler)
 	admin.serveMux.HandleFunc("/admin/server/log", admin.serverLogHandler)
 	admin.serveMux.HandleFunc("/admin/server/log/ws", admin.serverLogWebSocketHandler)
+	admin.serveMux.HandleFunc("/admin/results/upload", admin.resultsUploadHandler)
+	admin.serveMux.HandleFunc("/admin/results/failed", admin.resultsFailedHandler)
+	admin.serveMux.HandleFunc("/admin/results/events", admin.resultsEventsHandler)
 
 	return admin
 }
@@ -75,10 +88,12 @@ func (a *admin) loginHandler(w http.ResponseWriter, r *http.Request, session *se
 
 type adminIndexPage struct {
 	Server *accserver.Server
+	// FailedFiles is the number of results files which could not be loaded
+	FailedFiles int
 }
 
 func (a *admin) indexHandler(w http.ResponseWriter, r *http.Request) {
-	a.executeTemplate(w, r, "admin.html", &adminIndexPage{a.server})
+	a.executeTemplate(w, r, "admin.html", &adminIndexPage{a.server, a.frontend.db.FailedFileCount()})
 }
 
 func (a *admin) serverHandler(w http.ResponseWriter, r *http.Request) {
@@ -91,13 +106,75 @@ func (a *admin) serverStartHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := a.server.Start(); err != nil {
+	if err := a.server.Start(); errors.Is(err, accserver.ErrPreflightFailed) {
+		log.Printf("Not starting server: %v", err)
+	} else if err != nil {
 		log.Panicf("Failed to start server: %v", err)
 	}
 
 	http.Redirect(w, r, basePath(r)+"/admin/server", http.StatusSeeOther)
 }
 
+func (a *admin) serverPreflightHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	a.server.LastPreflight = a.server.Preflight()
+
+	http.Redirect(w, r, basePath(r)+"/admin/server", http.StatusSeeOther)
+}
+
+func (a *admin) serverRotateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if a.server.Rotation == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Rotating waits for the server to stop, so don't keep the request waiting
+	go a.server.RotateTrack()
+
+	http.Redirect(w, r, basePath(r)+"/admin/server", http.StatusSeeOther)
+}
+
+type adminServerQuickPage struct {
+	Message string
+	Server  *accserver.Server
+	// SessionTypes contains the default session type for each session in the form
+	SessionTypes []accserver.SessionType
+}
+
+func (a *admin) serverQuickHandler(w http.ResponseWriter, r *http.Request) {
+	var page = &adminServerQuickPage{
+		Message:      "",
+		Server:       a.server,
+		SessionTypes: []accserver.SessionType{accserver.Practice, accserver.Qualifying, accserver.Race},
+	}
+
+	if r.Method == "POST" {
+		if err := r.ParseForm(); err != nil {
+			log.Panicf("Failed to parse form on admin/server/quick: %v", err)
+		}
+		quick, err := a.parseQuickSessionForm(r.PostForm)
+		if err == nil {
+			err = a.server.StartQuickSession(quick)
+		}
+		if err != nil {
+			page.Message = strings.ReplaceAll(err.Error(), "\n", "<br>")
+		} else {
+			http.Redirect(w, r, basePath(r)+"/admin/server", http.StatusSeeOther)
+			return
+		}
+	}
+
+	a.executeTemplate(w, r, "admin-server-quick.html", page)
+}
+
 func (a *admin) serverStopHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -144,14 +221,16 @@ func (a *admin) cfgGlobalHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 type adminServerCfgEventPage struct {
-	Message string
-	Server  *accserver.Server
+	Message        string
+	Server         *accserver.Server
+	WeatherPresets []*accserver.WeatherPreset
 }
 
 func (a *admin) cfgEventHandler(w http.ResponseWriter, r *http.Request) {
 	var page = &adminServerCfgEventPage{
-		Message: "",
-		Server:  a.server,
+		Message:        "",
+		Server:         a.server,
+		WeatherPresets: accserver.WeatherPresets,
 	}
 
 	if r.Method == "POST" {
@@ -159,6 +238,10 @@ func (a *admin) cfgEventHandler(w http.ResponseWriter, r *http.Request) {
 			log.Panicf("Failed to parse form on admin/server/cfg/event: %v", err)
 		}
 		event, err := a.parseServerCfgEventForm(r.PostForm)
+		var weather *accserver.WeatherRecord
+		if err == nil {
+			weather, err = a.parseServerCfgEventFormWeather(r.PostForm, event)
+		}
 		if err != nil {
 			page.Message = strings.ReplaceAll(err.Error(), "\n", "<br>")
 		} else {
@@ -166,6 +249,11 @@ func (a *admin) cfgEventHandler(w http.ResponseWriter, r *http.Request) {
 			if err := a.server.SaveConfiguration(); err != nil {
 				log.Panic(err.Error())
 			}
+			if weather != nil && a.server.WeatherHistory != nil {
+				if err := a.server.WeatherHistory.Record(weather); err != nil {
+					log.Printf("Cannot record weather: %v", err)
+				}
+			}
 			http.Redirect(w, r, basePath(r)+"/admin/server", http.StatusSeeOther)
 			return
 		}
@@ -174,6 +262,59 @@ func (a *admin) cfgEventHandler(w http.ResponseWriter, r *http.Request) {
 	a.executeTemplate(w, r, "admin-server-cfg-event.html", page)
 }
 
+type adminServerCfgWizardPage struct {
+	Message   string
+	Server    *accserver.Server
+	Templates []*accserver.EventTemplate
+	Template  *accserver.EventTemplate
+	Track     string
+	Event     *accserver.CfgEvent
+	Rules     *accserver.CfgEventRules
+	Timeline  []*accserver.TimelineSession
+}
+
+func (a *admin) cfgWizardHandler(w http.ResponseWriter, r *http.Request) {
+	var page = &adminServerCfgWizardPage{
+		Message:   "",
+		Server:    a.server,
+		Templates: a.server.EventTemplates(),
+		Track:     a.server.Cfg.Event.Track,
+	}
+	page.Template = page.Templates[0]
+
+	if r.Method == "POST" {
+		if err := r.ParseForm(); err != nil {
+			log.Panicf("Failed to parse form on admin/server/cfg/wizard: %v", err)
+		}
+		if template := a.server.EventTemplate(r.PostForm.Get("template")); template != nil {
+			page.Template = template
+		} else {
+			page.Message = "Unknown template"
+		}
+		if track := accdata.TrackByLabel(r.PostForm.Get("track")); track != nil {
+			page.Track = track.Label
+		} else {
+			page.Message = "Unknown track"
+		}
+	}
+
+	page.Event = page.Template.ApplyTo(a.server.Cfg.Event, page.Track)
+	page.Rules = page.Template.ApplyRulesTo(a.server.Cfg.EventRules)
+	page.Timeline = accserver.EventTimeline(page.Event)
+
+	if r.Method == "POST" && page.Message == "" && r.PostForm.Get("save") != "" {
+		a.server.Cfg.Event = page.Event
+		a.server.Cfg.EventRules = page.Rules
+		if err := a.server.SaveConfiguration(); err != nil {
+			log.Panic(err.Error())
+		}
+		http.Redirect(w, r, basePath(r)+"/admin/server", http.StatusSeeOther)
+		return
+	}
+
+	a.executeTemplate(w, r, "admin-server-cfg-wizard.html", page)
+}
+
 func (a *admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	sessionName := "admin-session"
 	session, err := a.store.Get(r, sessionName)
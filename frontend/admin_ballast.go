@@ -0,0 +1,47 @@
+package frontend
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/geniusdex/racce/accresults"
+	"github.com/geniusdex/racce/accserver"
+)
+
+type adminServerBallastPage struct {
+	Message string
+	Server  *accserver.Server
+	Rules   *accserver.SuccessBallastRules
+	Races   []*accresults.Session
+	Drivers []*accserver.DriverBallast
+}
+
+func (a *admin) serverBallastHandler(w http.ResponseWriter, r *http.Request) {
+	var page = &adminServerBallastPage{
+		Server: a.server,
+		Rules:  a.server.Config.SuccessBallast,
+	}
+
+	if page.Rules == nil {
+		page.Message = "No success ballast rules are configured"
+	} else {
+		page.Races = recentSessions(a.frontend.db, []accresults.SessionType{accresults.Race}, time.Time{}, page.Rules.Sessions)
+		drivers, err := accserver.ComputeSuccessBallast(page.Rules, page.Races)
+		if err != nil {
+			page.Message = err.Error()
+		}
+		page.Drivers = drivers
+	}
+
+	if r.Method == "POST" && page.Drivers != nil {
+		a.server.Cfg.EntryList = a.server.Cfg.EntryList.WithBallast(page.Drivers)
+		if err := a.server.SaveConfiguration(); err != nil {
+			log.Panic(err.Error())
+		}
+		http.Redirect(w, r, basePath(r)+"/admin/server", http.StatusSeeOther)
+		return
+	}
+
+	a.executeTemplate(w, r, "admin-server-ballast.html", page)
+}
@@ -0,0 +1,94 @@
+package frontend
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/geniusdex/racce/accresults"
+)
+
+const (
+	// adminEventsShown is the number of most recent events which can be split or merged on the admin pages
+	adminEventsShown = 30
+)
+
+type adminEventSession struct {
+	*accresults.Session
+	Override string
+	// CanSplit is set if a new event can be started at this session
+	CanSplit bool
+	// CanMerge is set if this session can be added to the previous event
+	CanMerge bool
+}
+
+type adminEvent struct {
+	*accresults.Event
+	Sessions []*adminEventSession
+}
+
+type adminResultsEventsPage struct {
+	Message string
+	Events  []*adminEvent
+}
+
+func (a *admin) resultsEventsHandler(w http.ResponseWriter, r *http.Request) {
+	var page = &adminResultsEventsPage{}
+	db := a.frontend.db
+
+	if r.Method == "POST" {
+		if err := r.ParseForm(); err != nil {
+			log.Panicf("Failed to parse form on admin/results/events: %v", err)
+		}
+
+		sessionName := r.PostForm.Get("session")
+		override := r.PostForm.Get("override")
+		if err := db.SetEventOverride(sessionName, override); err != nil {
+			page.Message = fmt.Sprintf("Cannot change the events: %v", err)
+		} else if override == "" {
+			page.Message = fmt.Sprintf("Removed the manual change at session %s", sessionName)
+		} else {
+			page.Message = fmt.Sprintf("Changed the events at session %s", sessionName)
+		}
+	}
+
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	events := make([]*accresults.Event, 0, len(db.Events))
+	for _, event := range db.Events {
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].EndTime.After(events[j].EndTime)
+	})
+
+	// An event can only be merged with the previous event of its source when both are on the same track
+	previousEvents := make(map[*accresults.Event]*accresults.Event)
+	lastEvents := make(map[string]*accresults.Event)
+	for i := len(events) - 1; i >= 0; i-- {
+		previousEvents[events[i]] = lastEvents[events[i].Source]
+		lastEvents[events[i].Source] = events[i]
+	}
+
+	if len(events) > adminEventsShown {
+		events = events[:adminEventsShown]
+	}
+
+	for _, event := range events {
+		e := &adminEvent{event, make([]*adminEventSession, 0, len(event.Sessions))}
+		previous := previousEvents[event]
+		for i, session := range event.Sessions {
+			e.Sessions = append(e.Sessions, &adminEventSession{
+				Session:  session,
+				Override: db.EventOverride(session.SessionName),
+				CanSplit: i > 0,
+				CanMerge: i == 0 && previous != nil && previous.TrackName == event.TrackName,
+			})
+		}
+		page.Events = append(page.Events, e)
+	}
+
+	a.executeTemplate(w, r, "admin-results-events.html", page)
+}
@@ -0,0 +1,47 @@
+package frontend
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/geniusdex/racce/accresults"
+)
+
+type adminResultsFailedPage struct {
+	Message string
+	Files   []*accresults.FailedFile
+}
+
+func (a *admin) resultsFailedHandler(w http.ResponseWriter, r *http.Request) {
+	var page = &adminResultsFailedPage{}
+
+	if r.Method == "POST" {
+		if err := r.ParseForm(); err != nil {
+			log.Panicf("Failed to parse form on admin/results/failed: %v", err)
+		}
+
+		retry := a.frontend.db.FailedFiles()
+		if r.PostForm.Get("all") == "" {
+			retry = []*accresults.FailedFile{{Source: r.PostForm.Get("source"), FileName: r.PostForm.Get("file")}}
+		}
+
+		loaded := 0
+		problems := make([]string, 0)
+		for _, file := range retry {
+			if err := a.frontend.db.RetryFailedFile(file.Source, file.FileName); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", file.FileName, err))
+			} else {
+				loaded++
+			}
+		}
+		page.Message = fmt.Sprintf("Loaded %d results files", loaded)
+		if len(problems) > 0 {
+			page.Message += "; still failing: " + strings.Join(problems, "; ")
+		}
+	}
+
+	page.Files = a.frontend.db.FailedFiles()
+	a.executeTemplate(w, r, "admin-results-failed.html", page)
+}
@@ -6,10 +6,14 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/geniusdex/racce/accserver"
 )
 
+// quickSessionMaxSessions is the number of sessions which can be entered in the quick session form
+const quickSessionMaxSessions = 3
+
 type errorStore struct {
 	errors []string
 }
@@ -166,6 +170,66 @@ func (a *admin) parseServerCfgEventForm(form url.Values) (*accserver.CfgEvent, e
 	return event, errors.Error()
 }
 
+// parseServerCfgEventFormWeather applies the weather preset or random weather selected in the given event configuration
+// form to the event. It returns a record of the chosen weather, or nil if the weather was set manually.
+func (a *admin) parseServerCfgEventFormWeather(form url.Values, event *accserver.CfgEvent) (*accserver.WeatherRecord, error) {
+	source := form.Get("weatherPreset")
+	if source == "" {
+		return nil, nil
+	}
+
+	record := &accserver.WeatherRecord{
+		Time:   time.Now(),
+		Track:  event.Track,
+		Source: source,
+	}
+	if source == accserver.WeatherRandom {
+		if seed := form.Get("weatherSeed"); seed == "" {
+			record.Seed = time.Now().UnixNano() % 1000000
+		} else if parsed, err := strconv.ParseInt(seed, 10, 64); err != nil {
+			return nil, fmt.Errorf("Field weatherSeed is not a valid integer: %w", err)
+		} else {
+			record.Seed = parsed
+		}
+		record.Weather = accserver.GenerateWeather(event.Track, record.Seed)
+	} else if preset := accserver.WeatherPresetByName(source); preset != nil {
+		record.Weather = preset.Weather
+	} else {
+		return nil, fmt.Errorf("Unknown weather preset %s", source)
+	}
+
+	record.Weather.ApplyTo(event)
+	return record, nil
+}
+
+// parseQuickSessionForm parses the given quick session form
+func (a *admin) parseQuickSessionForm(form url.Values) (*accserver.QuickSession, error) {
+	parser := newFormParser(form)
+
+	quick := &accserver.QuickSession{
+		Track:    parser.String("track"),
+		Sessions: make([]*accserver.CfgEventSession, 0),
+		Password: parser.String("password"),
+	}
+
+	for i := 0; i < quickSessionMaxSessions; i++ {
+		prefix := fmt.Sprintf("sessions[%d].", i)
+		duration := parser.Int(prefix + "sessionDurationMinutes")
+		if duration <= 0 {
+			continue
+		}
+		quick.Sessions = append(quick.Sessions, &accserver.CfgEventSession{
+			HourOfDay:              parser.Int(prefix + "hourOfDay"),
+			DayOfWeekend:           int(accserver.Saturday),
+			TimeMultiplier:         1,
+			SessionType:            accserver.SessionType(parser.String(prefix + "sessionType")),
+			SessionDurationMinutes: duration,
+		})
+	}
+
+	return quick, parser.Error()
+}
+
 // parseServerCfgGlobalForm parses the given global server configuration form and returns new configuration and settings objects
 func (a *admin) parseServerCfgGlobalForm(form url.Values) (*accserver.CfgConfiguration, *accserver.CfgSettings, error) {
 	parser := newFormParser(form)
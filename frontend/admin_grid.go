@@ -0,0 +1,98 @@
+package frontend
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/geniusdex/racce/accresults"
+	"github.com/geniusdex/racce/accserver"
+)
+
+const (
+	// maxGridSourceSessions is the number of recent sessions which can be used to generate a grid
+	maxGridSourceSessions = 50
+)
+
+// recentSessions returns the most recent sessions of the given types which ended at or before the given time, most
+// recent first. A zero time includes all sessions.
+func recentSessions(db *accresults.Database, types []accresults.SessionType, until time.Time, max int) []*accresults.Session {
+	db.Mutex.RLock()
+	defer db.Mutex.RUnlock()
+
+	sessions := make([]*accresults.Session, 0)
+	for _, session := range db.Sessions {
+		if !until.IsZero() && session.EndTime.After(until) {
+			continue
+		}
+		for _, sessionType := range types {
+			if session.SessionType == sessionType {
+				sessions = append(sessions, session)
+				break
+			}
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].EndTime.After(sessions[j].EndTime)
+	})
+	if len(sessions) > max {
+		sessions = sessions[:max]
+	}
+	return sessions
+}
+
+type adminServerGridPage struct {
+	Message           string
+	Server            *accserver.Server
+	Sessions          []*accresults.Session
+	Session           *accresults.Session
+	Rules             []accserver.GridRule
+	Rule              accserver.GridRule
+	N                 int
+	ChampionshipRaces int
+	Grid              []*accresults.Car
+}
+
+func (a *admin) serverGridHandler(w http.ResponseWriter, r *http.Request) {
+	var page = &adminServerGridPage{
+		Server:            a.server,
+		Sessions:          recentSessions(a.frontend.db, []accresults.SessionType{accresults.Qualifying, accresults.Race}, time.Time{}, maxGridSourceSessions),
+		Rules:             accserver.GridRules,
+		Rule:              accserver.GridRule(r.FormValue("rule")),
+		N:                 intFormValue(r, "n", 10),
+		ChampionshipRaces: intFormValue(r, "championshipRaces", 10),
+	}
+	if page.Rule == "" {
+		page.Rule = accserver.GridStraight
+	}
+
+	for _, session := range page.Sessions {
+		if session.SessionName == r.FormValue("session") {
+			page.Session = session
+		}
+	}
+	if page.Session == nil && len(page.Sessions) > 0 {
+		page.Session = page.Sessions[0]
+	}
+
+	if page.Session != nil {
+		championship := recentSessions(a.frontend.db, []accresults.SessionType{accresults.Race}, page.Session.EndTime, page.ChampionshipRaces)
+		grid, err := accserver.GenerateGrid(page.Session, page.Rule, page.N, championship)
+		if err != nil {
+			page.Message = err.Error()
+		}
+		page.Grid = grid
+	}
+
+	if r.Method == "POST" && page.Grid != nil && r.FormValue("save") != "" {
+		a.server.Cfg.EntryList = a.server.Cfg.EntryList.WithGrid(page.Grid)
+		if err := a.server.SaveConfiguration(); err != nil {
+			log.Panic(err.Error())
+		}
+		http.Redirect(w, r, basePath(r)+"/admin/server", http.StatusSeeOther)
+		return
+	}
+
+	a.executeTemplate(w, r, "admin-server-grid.html", page)
+}
@@ -0,0 +1,256 @@
+package frontend
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/geniusdex/racce/accresults"
+	"github.com/gorilla/securecookie"
+)
+
+const (
+	// maxUploadSize is the maximum size of all files in a single upload
+	maxUploadSize = 64 << 20
+	// uploadExpiry is the time an upload is kept for importing after the preview was shown
+	uploadExpiry = time.Hour
+)
+
+// uploadedFile is a results file in an upload, with the session parsed from it
+type uploadedFile struct {
+	Name     string
+	Contents []byte
+	// Session is the parsed session, or nil if the file is not valid
+	Session *accresults.Session
+	// Error describes why the file is not valid
+	Error string
+}
+
+// upload contains all results files uploaded at once, waiting to be imported
+type upload struct {
+	ID    string
+	Time  time.Time
+	Files []*uploadedFile
+}
+
+// Valid returns the number of valid files in the upload
+func (u *upload) Valid() int {
+	valid := 0
+	for _, file := range u.Files {
+		if file.Session != nil {
+			valid++
+		}
+	}
+	return valid
+}
+
+// uploadStore keeps uploads between showing the preview and importing them
+type uploadStore struct {
+	mutex   sync.Mutex
+	uploads map[string]*upload
+}
+
+func newUploadStore() *uploadStore {
+	return &uploadStore{uploads: make(map[string]*upload)}
+}
+
+// add stores a new upload and forgets all expired uploads
+func (s *uploadStore) add(u *upload) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for id, existing := range s.uploads {
+		if time.Since(existing.Time) > uploadExpiry {
+			delete(s.uploads, id)
+		}
+	}
+	s.uploads[u.ID] = u
+}
+
+// take removes an upload from the store and returns it, or nil if it does not exist
+func (s *uploadStore) take(id string) *upload {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	u := s.uploads[id]
+	delete(s.uploads, id)
+	return u
+}
+
+// validateUploadedFile parses an uploaded results file to check if it can be imported
+func validateUploadedFile(file *uploadedFile) {
+	if !accresults.IsSessionFile(file.Name) {
+		file.Error = "Not the name of a session results file"
+		return
+	}
+
+	tmpFile, err := ioutil.TempFile("", "racce-upload-*.json")
+	if err != nil {
+		log.Panicf("Cannot create temporary file for upload: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(file.Contents)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Panicf("Cannot write temporary file for upload: %v", err)
+	}
+
+	if session, err := accresults.LoadSessionFromFile(tmpFile.Name(), time.Time{}); err != nil {
+		file.Error = err.Error()
+	} else {
+		file.Session = session
+	}
+}
+
+// readUploadedZip returns all JSON files in a zip archive
+func readUploadedZip(name string, contents []byte) ([]*uploadedFile, error) {
+	archive, err := zip.NewReader(bytes.NewReader(contents), int64(len(contents)))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read zip archive '%s': %w", name, err)
+	}
+
+	files := make([]*uploadedFile, 0)
+	var extracted int64
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() || !strings.HasSuffix(strings.ToLower(entry.Name), ".json") {
+			continue
+		}
+		reader, err := entry.Open()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read '%s' in zip archive '%s': %w", entry.Name, name, err)
+		}
+		// The sizes in the archive cannot be trusted, so at most one byte more than the remaining size is read
+		entryContents, err := ioutil.ReadAll(io.LimitReader(reader, maxUploadSize-extracted+1))
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read '%s' in zip archive '%s': %w", entry.Name, name, err)
+		}
+		extracted += int64(len(entryContents))
+		if extracted > maxUploadSize {
+			return nil, fmt.Errorf("zip archive '%s' extracts to more than %d MB", name, maxUploadSize>>20)
+		}
+		files = append(files, &uploadedFile{Name: path.Base(entry.Name), Contents: entryContents})
+	}
+	return files, nil
+}
+
+// parseUpload reads all uploaded files, extracting zip archives, and validates them
+func parseUpload(w http.ResponseWriter, r *http.Request) (*upload, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		return nil, fmt.Errorf("cannot read upload: %w", err)
+	}
+
+	u := &upload{
+		ID:    fmt.Sprintf("%x", securecookie.GenerateRandomKey(16)),
+		Time:  time.Now(),
+		Files: make([]*uploadedFile, 0),
+	}
+	for _, header := range r.MultipartForm.File["files"] {
+		file, err := header.Open()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read '%s': %w", header.Filename, err)
+		}
+		contents, err := ioutil.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read '%s': %w", header.Filename, err)
+		}
+
+		if strings.HasSuffix(strings.ToLower(header.Filename), ".zip") {
+			files, err := readUploadedZip(header.Filename, contents)
+			if err != nil {
+				return nil, err
+			}
+			u.Files = append(u.Files, files...)
+		} else {
+			u.Files = append(u.Files, &uploadedFile{Name: path.Base(header.Filename), Contents: contents})
+		}
+	}
+	if len(u.Files) == 0 {
+		return nil, fmt.Errorf("no results files were uploaded")
+	}
+
+	names := make(map[string]bool)
+	for _, file := range u.Files {
+		if names[file.Name] {
+			file.Error = "Duplicate file name in upload"
+			continue
+		}
+		names[file.Name] = true
+		validateUploadedFile(file)
+	}
+	return u, nil
+}
+
+type adminResultsUploadPage struct {
+	Message string
+	Sources []string
+	Upload  *upload
+}
+
+func (a *admin) resultsUploadHandler(w http.ResponseWriter, r *http.Request) {
+	var page = &adminResultsUploadPage{
+		Sources: a.frontend.db.SourceNames(),
+	}
+
+	// Files are uploaded as a multipart form; importing them afterwards is a regular form
+	if r.Method == "POST" && strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		upload, err := parseUpload(w, r)
+		if err != nil {
+			page.Message = err.Error()
+		} else {
+			a.uploads.add(upload)
+			page.Upload = upload
+		}
+	} else if r.Method == "POST" {
+		if err := r.ParseForm(); err != nil {
+			log.Panicf("Failed to parse form on admin/results/upload: %v", err)
+		}
+		upload := a.uploads.take(r.PostForm.Get("upload"))
+		if upload == nil {
+			page.Message = "The upload has expired; please upload the files again"
+		} else {
+			page.Message = a.importUpload(upload, r.PostForm.Get("source"), r.PostForm["file"])
+		}
+	}
+
+	a.executeTemplate(w, r, "admin-results-upload.html", page)
+}
+
+// importUpload stores the selected valid files of an upload in a source, and describes the outcome
+func (a *admin) importUpload(u *upload, source string, selected []string) string {
+	isSelected := make(map[string]bool)
+	for _, name := range selected {
+		isSelected[name] = true
+	}
+
+	imported := 0
+	problems := make([]string, 0)
+	for _, file := range u.Files {
+		if file.Session == nil || !isSelected[file.Name] {
+			continue
+		}
+		if err := a.frontend.db.ImportSessionFile(source, file.Name, file.Contents); err != nil {
+			problems = append(problems, err.Error())
+		} else {
+			log.Printf("Imported uploaded results file '%s' into source '%s'", file.Name, source)
+			imported++
+		}
+	}
+
+	message := fmt.Sprintf("Imported %d results files into source '%s'", imported, source)
+	if len(problems) > 0 {
+		message += "; not imported: " + strings.Join(problems, "; ")
+	}
+	return message
+}
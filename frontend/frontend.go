@@ -55,7 +55,7 @@ func (f *frontend) addTemplateFunctions(t *template.Template, templateData *temp
 			return a + b
 		},
 		"sub": func(a, b int) int {
-			return a + b
+			return a - b
 		},
 		"div": func(a, b int) float64 {
 			return float64(a) / float64(b)
@@ -112,10 +112,25 @@ func (f *frontend) addTemplateFunctions(t *template.Template, templateData *temp
 		"drivercategories": func() []*accdata.CupCategory {
 			return accdata.CupCategories
 		},
+		"nationality": func(id int) *accdata.Nationality {
+			if nationality := accdata.NationalityByID(id); nationality != nil {
+				return nationality
+			}
+			return &accdata.Nationality{ID: 0, Name: "-"}
+		},
 		// Information about racce instance
 		"isLiveStateEnabled": func() bool {
 			return f.config.Live
 		},
+		"isHotlapsEnabled": func() bool {
+			return f.server != nil && f.server.Hotlaps != nil
+		},
+		"resultSources": func() []string {
+			return f.db.SourceNames()
+		},
+		"loadProgress": func() accresults.LoadProgress {
+			return f.db.LoadProgress()
+		},
 	})
 	return t
 }
@@ -188,6 +203,9 @@ func Run(config *Configuration, database *accresults.Database, server *accserver
 		http.HandleFunc("/live/", f.liveHandler)
 		http.HandleFunc("/live/ws", f.liveWebSocketHandler)
 	}
+	if server != nil && server.Hotlaps != nil {
+		http.HandleFunc("/hotlaps/", f.hotlapsHandler)
+	}
 
 	admin := newAdmin(config, server, f)
 	http.Handle("/admin/", admin)
@@ -0,0 +1,65 @@
+package frontend
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/geniusdex/racce/accserver"
+)
+
+type hotlapsPage struct {
+	Windows     []*accserver.HotlapWindow
+	WindowIndex int
+	Window      *accserver.HotlapWindow
+	Start       time.Time
+	End         time.Time
+	TrackName   string
+	CarModel    int
+	Laps        []*accserver.Hotlap
+}
+
+// intFormValue returns the form value with the given key as integer, or the default value if missing or invalid
+func intFormValue(r *http.Request, key string, defaultValue int) int {
+	if value, err := strconv.Atoi(r.FormValue(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func (f *frontend) hotlapsHandler(w http.ResponseWriter, r *http.Request) {
+	board := f.server.Hotlaps
+	page := &hotlapsPage{
+		Windows:     board.Windows(),
+		WindowIndex: intFormValue(r, "window", 0),
+		TrackName:   r.FormValue("track"),
+		CarModel:    intFormValue(r, "car", -1),
+	}
+
+	if page.WindowIndex < 0 || page.WindowIndex >= len(page.Windows) {
+		page.WindowIndex = 0
+	}
+	page.Window = page.Windows[page.WindowIndex]
+	if page.TrackName == "" {
+		page.TrackName = f.server.LiveState.Track.Label
+	}
+
+	now := time.Now()
+	start, end, err := page.Window.Bounds(now)
+	if err != nil {
+		log.Print(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page.Start = start
+	page.End = end.Add(-time.Second)
+
+	if page.Laps, err = board.Leaderboard(page.Window, now, page.TrackName, page.CarModel); err != nil {
+		log.Print(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	f.executeTemplate(w, r, "hotlaps.html", page)
+}
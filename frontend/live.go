@@ -84,6 +84,12 @@ func (f *frontend) sendLiveStateUpdates(ws webSocketWriter) {
 				return
 			}
 			writeMessageToWebSocket(ws, "carPurged", carID)
+
+		case lap, ok := <-events.Lap:
+			if !ok {
+				return
+			}
+			writeMessageToWebSocket(ws, "lap", lap)
 		}
 	}
 }
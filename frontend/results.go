@@ -11,6 +11,97 @@ import (
 	"github.com/geniusdex/racce/accresults"
 )
 
+// indexPlayer is a player on the index, with only the events of the selected source
+type indexPlayer struct {
+	*accresults.Player
+	Events map[string]*accresults.Event
+}
+
+// indexPage contains the events and players on the index, optionally limited to a single source
+type indexPage struct {
+	// Source is the name of the selected source, or empty for all sources
+	Source  string
+	Events  map[string]*accresults.Event
+	Players []*indexPlayer
+}
+
+// eventPage contains an event and its neighbouring events, optionally limited to a single source
+type eventPage struct {
+	Event *accresults.Event
+	// Source is the name of the selected source, or empty for all sources
+	Source string
+	// Previous is the event before this one in the selected source, or nil if there is none
+	Previous *accresults.Event
+	// Next is the event after this one in the selected source, or nil if there is none
+	Next *accresults.Event
+}
+
+// selectedSource returns the source selected in the request, or an empty string for all sources
+func (f *frontend) selectedSource(r *http.Request) string {
+	for _, source := range f.db.SourceNames() {
+		if r.FormValue("source") == source {
+			return source
+		}
+	}
+	return ""
+}
+
+// newIndexPage creates the index for the source selected in the request; the database must be locked for reading
+func (f *frontend) newIndexPage(r *http.Request) *indexPage {
+	page := &indexPage{
+		Source:  f.selectedSource(r),
+		Events:  f.db.Events,
+		Players: make([]*indexPlayer, 0, len(f.db.Players)),
+	}
+
+	if page.Source == "" {
+		for _, player := range f.db.Players {
+			page.Players = append(page.Players, &indexPlayer{player, player.Events})
+		}
+		return page
+	}
+
+	page.Events = make(map[string]*accresults.Event)
+	for eventID, event := range f.db.Events {
+		if event.Source == page.Source {
+			page.Events[eventID] = event
+		}
+	}
+	for _, player := range f.db.Players {
+		events := make(map[string]*accresults.Event)
+		for eventID, event := range player.Events {
+			if event.Source == page.Source {
+				events[eventID] = event
+			}
+		}
+		if len(events) > 0 {
+			page.Players = append(page.Players, &indexPlayer{player, events})
+		}
+	}
+	return page
+}
+
+// newEventPage creates the page for an event with navigation through the source selected in the request; the
+// database must be locked for reading
+func (f *frontend) newEventPage(r *http.Request, event *accresults.Event) *eventPage {
+	page := &eventPage{
+		Event:  event,
+		Source: f.selectedSource(r),
+	}
+	for _, other := range f.db.Events {
+		if other == event || (page.Source != "" && other.Source != page.Source) {
+			continue
+		}
+		if other.EndTime.Before(event.EndTime) && (page.Previous == nil || other.EndTime.After(page.Previous.EndTime)) {
+			page.Previous = other
+		}
+		if other.EndTime.After(event.EndTime) && (page.Next == nil || other.EndTime.Before(page.Next.EndTime)) {
+			page.Next = other
+		}
+	}
+	return page
+}
+
 func (f *frontend) indexHandler(w http.ResponseWriter, r *http.Request) {
 	if len(strings.Trim(r.URL.Path, "/")) > 0 {
 		w.WriteHeader(http.StatusNotFound)
@@ -20,14 +111,14 @@ func (f *frontend) indexHandler(w http.ResponseWriter, r *http.Request) {
 	f.db.Mutex.RLock()
 	defer f.db.Mutex.RUnlock()
 
-	f.executeTemplate(w, r, "index.html", f.db)
+	f.executeTemplate(w, r, "index.html", f.newIndexPage(r))
 }
 
 func (f *frontend) indexFullHandler(w http.ResponseWriter, r *http.Request) {
 	f.db.Mutex.RLock()
 	defer f.db.Mutex.RUnlock()
 
-	f.executeTemplate(w, r, "index-full.html", f.db)
+	f.executeTemplate(w, r, "index-full.html", f.newIndexPage(r))
 }
 
 func (f *frontend) eventHandler(w http.ResponseWriter, r *http.Request) {
@@ -49,7 +140,7 @@ func (f *frontend) eventHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	f.executeTemplate(w, r, "event.html", event)
+	f.executeTemplate(w, r, "event.html", f.newEventPage(r, event))
 }
 
 type playerPage struct {
@@ -147,6 +238,7 @@ func (f *frontend) sessionHandler(w http.ResponseWriter, r *http.Request) {
 type sessionCarPage struct {
 	Session *accresults.Session
 	Car     *accresults.Car
+	Line    *accresults.LeaderBoardLine
 }
 
 func (f *frontend) sessionCarHandler(w http.ResponseWriter, r *http.Request) {
@@ -173,12 +265,12 @@ func (f *frontend) sessionCarHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
-	car := session.FindCarById(carID)
-	if car == nil {
+	line := session.FindLineByCarId(carID)
+	if line == nil {
 		log.Printf("Car ID '%d' not present in session", carID)
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
-	f.executeTemplate(w, r, "sessioncar.html", &sessionCarPage{session, car})
+	f.executeTemplate(w, r, "sessioncar.html", &sessionCarPage{session, line.Car, line})
 }
@@ -4,26 +4,76 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
 
 	"github.com/geniusdex/racce/accresults"
 	"github.com/geniusdex/racce/accserver"
 	"github.com/geniusdex/racce/frontend"
 )
 
+const (
+	// serverResultsSourceName is the name of the results source for the results dir of the accServer
+	serverResultsSourceName = "server"
+)
+
+// resultsConfiguration contains the options for the results database and any additional sources of results files
+type resultsConfiguration struct {
+	accresults.Options
+	Sources       []*accresults.Source     `json:"sources"`
+	EventGrouping accresults.EventGrouping `json:"eventGrouping"`
+}
+
 type configuration struct {
+	DataDir  string                  `json:"dataDir"`
 	Frontend frontend.Configuration  `json:"frontend"`
-	Results  accresults.Options      `json:"results"`
+	Results  resultsConfiguration    `json:"results"`
 	Server   accserver.Configuration `json:"server"`
 }
 
+// resultsSources returns all sources of results files; the results dir of the accServer comes first, if configured,
+// and is the only source whose session names are not prefixed
+func (c *configuration) resultsSources() []*accresults.Source {
+	sources := make([]*accresults.Source, 0)
+	if c.Server.InstallationDir != "" || c.Server.ResultsDir != "" {
+		sources = append(sources, accresults.NewPrimarySource(serverResultsSourceName, c.Server.ResolveResultsDir()))
+	}
+	return append(sources, c.Results.Sources...)
+}
+
 func (c *configuration) makeDatabaseConfiguration() *accresults.Configuration {
+	cachePath, err := c.dataPath("results-cache.gob")
+	if err != nil {
+		log.Printf("Results cannot be cached: %v", err)
+	}
+	overridesPath, err := c.dataPath("event-overrides.json")
+	if err != nil {
+		log.Printf("Event overrides cannot be stored: %v", err)
+	}
+
 	return &accresults.Configuration{
-		ResultsDir:   c.Server.ResolveResultsDir(),
-		NewFileDelay: c.Server.NewResultsDelay,
-		Options:      c.Results,
+		Sources:            c.resultsSources(),
+		NewFileDelay:       c.Server.NewResultsDelay,
+		PollInterval:       c.Server.ResultsPollInterval,
+		Options:            c.Results.Options,
+		CachePath:          cachePath,
+		EventGrouping:      c.Results.EventGrouping,
+		EventOverridesPath: overridesPath,
 	}
 }
 
+// dataPath returns the path of a file in the data directory of racce, creating the directory if needed
+func (c *configuration) dataPath(name string) (string, error) {
+	dataDir := c.DataDir
+	if dataDir == "" {
+		dataDir = "data"
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, name), nil
+}
+
 func loadConfiguration(filename string) (*configuration, error) {
 	fileContents, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -50,12 +100,37 @@ func main() {
 		log.Printf("Server cannot be managed: %v", err)
 	}
 
-	log.Printf("Populating database...")
-	db, err := accresults.LoadDatabase(config.makeDatabaseConfiguration())
+	log.Printf("Populating database in the background...")
+	db, err := accresults.LoadDatabaseInBackground(config.makeDatabaseConfiguration())
 	if err != nil {
 		log.Panic(err)
 	}
 
+	if server != nil {
+		server.SetLapHistory(db)
+		if len(config.Server.Hotlaps) > 0 {
+			path, err := config.dataPath("hotlaps.json")
+			if err == nil {
+				err = server.EnableHotlaps(path)
+			}
+			if err != nil {
+				log.Printf("Hotlaps cannot be recorded: %v", err)
+			}
+		}
+		path, err := config.dataPath("weather.json")
+		if err == nil {
+			err = server.EnableWeatherHistory(path)
+		}
+		if err != nil {
+			log.Printf("Weather setups cannot be recorded: %v", err)
+		}
+		if config.Server.Rotation != nil {
+			if err := server.EnableTrackRotation(config.Server.Rotation); err != nil {
+				log.Printf("Tracks cannot be rotated: %v", err)
+			}
+		}
+	}
+
 	log.Printf("Starting frontend...")
 	log.Panic(frontend.Run(&config.Frontend, db, server))
 }